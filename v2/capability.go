@@ -0,0 +1,232 @@
+package grnci
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Minimum versions of features.
+var (
+	sortKeysVersion          = [3]int{6, 0, 3} // --sort_keys and --drilldown_sort_keys
+	labeledDrilldownsVersion = [3]int{5, 0, 1} // --drilldowns[LABEL]
+	dynamicColumnsVersion    = [3]int{7, 0, 0} // --columns[NAME]
+	slicesVersion            = [3]int{8, 0, 0} // --slices[LABEL]
+)
+
+// DBCapabilities stores features available on the server.
+type DBCapabilities struct {
+	Version               string          // Groonga version
+	VersionNumbers        [3]int          // Major, minor and micro version numbers
+	DefaultCommandVersion int             // Default command version
+	MaxCommandVersion     int             // Maximum command version
+	Commands              map[string]bool // Available commands (nil if unknown)
+	SortKeys              bool            // select --sort_keys
+	LabeledDrilldowns     bool            // select --drilldowns[LABEL]
+	DynamicColumns        bool            // select --columns[NAME]
+	Slices                bool            // select --slices[LABEL]
+}
+
+// parseVersion parses a version string, such as "7.0.1" and "7.0.1-12-g1234567".
+func parseVersion(s string) [3]int {
+	var numbers [3]int
+	if i := strings.IndexByte(s, '-'); i != -1 {
+		s = s[:i]
+	}
+	for i, part := range strings.SplitN(s, ".", 3) {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			break
+		}
+		numbers[i] = n
+	}
+	return numbers
+}
+
+// AtLeast returns whether or not the version is equal to or newer than
+// the specified version.
+func (c *DBCapabilities) AtLeast(version [3]int) bool {
+	for i := range version {
+		if c.VersionNumbers[i] != version[i] {
+			return c.VersionNumbers[i] > version[i]
+		}
+	}
+	return true
+}
+
+// HasCommand returns whether or not the command is available.
+// If the available commands are unknown, HasCommand returns true.
+func (c *DBCapabilities) HasCommand(name string) bool {
+	return c.Commands == nil || c.Commands[name]
+}
+
+// Capabilities probes the server and returns its capabilities.
+// The result is cached and the server is probed only once.
+func (db *DB) Capabilities() (*DBCapabilities, error) {
+	db.capsMutex.Lock()
+	defer db.capsMutex.Unlock()
+	if db.caps != nil {
+		return db.caps, nil
+	}
+	// The probe must not be affected by the compatibility mode.
+	raw := NewDB(db.Handler)
+	status, err := raw.Status()
+	if err != nil {
+		return nil, err
+	}
+	caps := &DBCapabilities{
+		Version:               status.Version,
+		VersionNumbers:        parseVersion(status.Version),
+		DefaultCommandVersion: status.DefaultCommandVersion,
+		MaxCommandVersion:     status.MaxCommandVersion,
+	}
+	caps.SortKeys = caps.AtLeast(sortKeysVersion)
+	caps.LabeledDrilldowns = caps.AtLeast(labeledDrilldownsVersion)
+	caps.DynamicColumns = caps.AtLeast(dynamicColumnsVersion)
+	caps.Slices = caps.AtLeast(slicesVersion)
+	// object_list is not available on old servers.
+	if objs, err := raw.ObjectList(); err == nil {
+		caps.Commands = make(map[string]bool)
+		for name, obj := range objs {
+			if obj != nil && obj.Type.Name == "proc" {
+				caps.Commands[name] = true
+			}
+		}
+	}
+	db.caps = caps
+	return caps, nil
+}
+
+// EnableCompat enables or disables the compatibility mode.
+// In the compatibility mode, DB probes the server on the first command
+// and adjusts parameters to the server capabilities,
+// e.g. --sort_keys is sent as --sortby to old servers.
+// Commands and parameters unavailable on the server are rejected.
+// The mode applies to commands sent via Exec, Invoke and Query,
+// including those of Restore.
+func (db *DB) EnableCompat(compat bool) {
+	db.capsMutex.Lock()
+	defer db.capsMutex.Unlock()
+	db.compat = compat
+}
+
+// compatEnabled returns whether or not the compatibility mode is enabled.
+func (db *DB) compatEnabled() bool {
+	db.capsMutex.Lock()
+	defer db.capsMutex.Unlock()
+	return db.compat
+}
+
+// Exec parses cmd, sends the parsed command and returns the response.
+// In the compatibility mode, the parameters are adjusted beforehand.
+// It is the caller's responsibility to close the response.
+func (db *DB) Exec(cmd string, body io.Reader) (Response, error) {
+	if !db.compatEnabled() {
+		return db.Handler.Exec(cmd, body)
+	}
+	command, err := ParseCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	command.SetBody(body)
+	return db.Query(command)
+}
+
+// Invoke assembles name and params into a command and sends it.
+// In the compatibility mode, the parameters are adjusted beforehand.
+// It is the caller's responsibility to close the response.
+func (db *DB) Invoke(name string, params map[string]interface{}, body io.Reader) (Response, error) {
+	if db.compatEnabled() {
+		caps, err := db.Capabilities()
+		if err != nil {
+			return nil, err
+		}
+		if params, err = caps.adjust(name, params); err != nil {
+			return nil, err
+		}
+	}
+	return db.Handler.Invoke(name, params, body)
+}
+
+// Query sends cmd and returns the response.
+// In the compatibility mode, the parameters are adjusted beforehand.
+// It is the caller's responsibility to close the response.
+func (db *DB) Query(cmd *Command) (Response, error) {
+	if !db.compatEnabled() {
+		return db.Handler.Query(cmd)
+	}
+	caps, err := db.Capabilities()
+	if err != nil {
+		return nil, err
+	}
+	params := make(map[string]interface{}, len(cmd.Params()))
+	for key, value := range cmd.Params() {
+		params[key] = value
+	}
+	if params, err = caps.adjust(cmd.Name(), params); err != nil {
+		return nil, err
+	}
+	adjusted, err := NewCommand(cmd.Name(), params)
+	if err != nil {
+		return nil, err
+	}
+	adjusted.SetBody(cmd.Body())
+	return db.Handler.Query(adjusted)
+}
+
+// newUnsupportedError returns an error for an unsupported feature.
+func (c *DBCapabilities) newUnsupportedError(msg, name, key string) *Error {
+	return NewError(CommandError, msg, map[string]interface{}{
+		"name":    name,
+		"key":     key,
+		"version": c.Version,
+	})
+}
+
+// adjust returns parameters adjusted to the capabilities.
+func (c *DBCapabilities) adjust(name string, params map[string]interface{}) (map[string]interface{}, error) {
+	if !c.HasCommand(name) {
+		return nil, NewError(CommandError, "The command is not available on the server.", map[string]interface{}{
+			"name":    name,
+			"version": c.Version,
+		})
+	}
+	adjusted := make(map[string]interface{}, len(params))
+	for key, value := range params {
+		if key == "command_version" && c.MaxCommandVersion != 0 {
+			if s, err := formatParamValue(key, value); err == nil && parseCommandVersion(s) > c.MaxCommandVersion {
+				return nil, c.newUnsupportedError("The command version is not supported by the server.", name, key)
+			}
+		}
+		if name == "select" || name == "logical_select" {
+			switch {
+			case strings.HasPrefix(key, "drilldowns["):
+				if !c.LabeledDrilldowns {
+					return nil, c.newUnsupportedError("Labeled drilldowns are not supported by the server.", name, key)
+				}
+			case strings.HasPrefix(key, "columns["):
+				if !c.DynamicColumns {
+					return nil, c.newUnsupportedError("Dynamic columns are not supported by the server.", name, key)
+				}
+			case strings.HasPrefix(key, "slices["):
+				if !c.Slices {
+					return nil, c.newUnsupportedError("Slices are not supported by the server.", name, key)
+				}
+			}
+			if !c.SortKeys && strings.HasSuffix(key, "sort_keys") {
+				key = strings.TrimSuffix(key, "sort_keys") + "sortby"
+			}
+		}
+		adjusted[key] = value
+	}
+	return adjusted, nil
+}
+
+// parseCommandVersion parses a command version and returns 0 on failure.
+func parseCommandVersion(s string) int {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+	return v
+}
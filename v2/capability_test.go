@@ -0,0 +1,93 @@
+package grnci
+
+import (
+	"strings"
+	"testing"
+)
+
+func newTestCompatDB(version string) (*DB, *testHandler) {
+	h := newTestHandler(func(cmd *Command, body string) (string, error) {
+		switch cmd.Name() {
+		case "status":
+			return `{"version":"` + version + `","default_command_version":1,"max_command_version":2}`, nil
+		case "object_list":
+			return `{"select":{"name":"select","type":{"id":1,"name":"proc"}},"status":{"name":"status","type":{"id":1,"name":"proc"}},"Tbl":{"name":"Tbl","type":{"id":2,"name":"table:hash_key"}}}`, nil
+		case "select":
+			return `[[[0],[]]]`, nil
+		}
+		return "true", nil
+	})
+	db := NewDB(h)
+	db.EnableCompat(true)
+	return db, h
+}
+
+func TestDBCapabilities(t *testing.T) {
+	db, h := newTestCompatDB("6.0.1-12-gabcdef")
+	caps, err := db.Capabilities()
+	if err != nil {
+		t.Fatalf("db.Capabilities failed: %v", err)
+	}
+	if caps.VersionNumbers != [3]int{6, 0, 1} || caps.SortKeys || !caps.LabeledDrilldowns || caps.DynamicColumns {
+		t.Fatalf("db.Capabilities failed: actual = %#v", caps)
+	}
+	if !caps.HasCommand("select") || caps.HasCommand("logical_range_filter") || caps.HasCommand("Tbl") {
+		t.Fatalf("db.Capabilities failed: Commands = %v", caps.Commands)
+	}
+	if _, err := db.Capabilities(); err != nil {
+		t.Fatalf("db.Capabilities failed: %v", err)
+	}
+	if len(h.commands) != 2 {
+		t.Fatalf("db.Capabilities failed: the server is probed %d times", len(h.commands))
+	}
+}
+
+func TestDBCompat(t *testing.T) {
+	db, h := newTestCompatDB("6.0.1")
+	options := NewDBSelectOptions()
+	options.SortKeys = []string{"-_score"}
+	result, err := db.Select("Tbl", options)
+	if err != nil {
+		t.Fatalf("db.Select failed: %v", err)
+	}
+	result.Close()
+	cmd := h.commands[len(h.commands)-1]
+	if _, ok := cmd.Params()["sort_keys"]; ok {
+		t.Fatalf("db.Select failed: actual = %s", cmd)
+	}
+	if actual, want := cmd.Params()["sortby"], "-_score"; actual != want {
+		t.Fatalf("db.Select failed: actual = %s, want = %s", actual, want)
+	}
+	options.Columns = map[string]*DBSelectOptionsColumn{
+		"x": {Stage: "initial", Type: "Int32", Value: "1"},
+	}
+	if _, err := db.Select("Tbl", options); err == nil {
+		t.Fatalf("db.Select wrongly succeeded with dynamic columns")
+	}
+	if _, err := db.LogicalRangeFilter("Logs", "timestamp", nil); err == nil {
+		t.Fatalf("db.LogicalRangeFilter wrongly succeeded")
+	}
+	if _, err := db.LoadIDs("Tbl", nil, nil); err == nil {
+		t.Fatalf("db.LoadIDs wrongly succeeded")
+	}
+}
+
+func TestDBCompatRestore(t *testing.T) {
+	db, h := newTestCompatDB("6.0.1")
+	dump := "select Tbl --sort_keys _key\nlogical_range_filter Logs timestamp\nstatus\n"
+	n, err := db.Restore(strings.NewReader(dump), nil, false)
+	if err == nil {
+		t.Fatalf("db.Restore wrongly succeeded")
+	}
+	if n != 3 {
+		t.Fatalf("db.Restore failed: actual = %d, want = %d", n, 3)
+	}
+	var names []string
+	for _, cmd := range h.commands {
+		names = append(names, cmd.String())
+	}
+	want := "status,object_list,select --sortby '_key' --table 'Tbl',status"
+	if actual := strings.Join(names, ","); actual != want {
+		t.Fatalf("db.Restore failed: actual = %s, want = %s", actual, want)
+	}
+}
@@ -0,0 +1,272 @@
+package grnci
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// DBCompareAndSetResult is a result of DB.CompareAndSet.
+type DBCompareAndSetResult struct {
+	Applied []int    // Indices of applied rows
+	Skipped []int    // Indices of rows skipped due to version mismatch or absence
+	IDs     []uint32 // Record IDs of rows (0 for skipped rows)
+}
+
+// casRows returns the rows as a slice of struct values.
+func casRows(rows interface{}) ([]reflect.Value, error) {
+	v := reflect.ValueOf(rows)
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil, NewError(CommandError, "The rows must not be nil.", nil)
+		}
+		if v = v.Elem(); v.Kind() == reflect.Struct {
+			return []reflect.Value{v}, nil
+		}
+		if v.Kind() == reflect.Array || v.Kind() == reflect.Slice {
+			return casRows(v.Interface())
+		}
+	case reflect.Array, reflect.Slice:
+		vs := make([]reflect.Value, v.Len())
+		for i := range vs {
+			vs[i] = v.Index(i)
+		}
+		return vs, nil
+	case reflect.Struct:
+		return []reflect.Value{v}, nil
+	}
+	return nil, NewError(CommandError, "The type is not supported.", map[string]interface{}{
+		"type": reflect.TypeOf(rows).Name(),
+	})
+}
+
+// CompareAndSet loads rows only if the stored version of each row equals
+// the version in the row, and increments the version of applied rows.
+//
+// The row struct must have _key or _id, and versionColumn must be
+// associated with an integer field.
+// If rows are addressable, the version fields of applied rows are updated.
+// Rows which do not exist are skipped because load would insert them.
+// Note that a row removed between the check and load is still inserted.
+func (db *DB) CompareAndSet(tbl string, rows interface{}, versionColumn string) (*DBCompareAndSetResult, error) {
+	rs, err := GetRowStruct(rows)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := rs.ColumnsByName["_key"]
+	if !ok {
+		if key, ok = rs.ColumnsByName["_id"]; !ok {
+			return nil, NewError(CommandError, "The row struct must have a _key or _id field.", map[string]interface{}{
+				"table": tbl,
+			})
+		}
+	}
+	if err := checkColumnName(versionColumn); err != nil {
+		return nil, err
+	}
+	version, ok := rs.ColumnsByName[versionColumn]
+	if !ok {
+		return nil, NewError(CommandError, "The column has no associated field.", map[string]interface{}{
+			"column": versionColumn,
+		})
+	}
+	switch version.Field.Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return nil, NewError(CommandError, "The version field must be an integer.", map[string]interface{}{
+			"column": versionColumn,
+			"type":   version.Field.Type.Name(),
+		})
+	}
+	vs, err := casRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	var cfs []*ColumnField
+	options := NewDBLoadOptions()
+	for _, cf := range rs.Columns {
+		if cf.Loadable {
+			options.Columns = append(options.Columns, cf.Name)
+			cfs = append(cfs, cf)
+		}
+	}
+
+	result := &DBCompareAndSetResult{
+		IDs: make([]uint32, len(vs)),
+	}
+	exists, err := db.casExists(tbl, key, vs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows are grouped by the expected version because --ifexists is shared.
+	var versions []int64
+	groups := make(map[int64][]int)
+	for i, v := range vs {
+		if !exists[i] {
+			result.Skipped = append(result.Skipped, i)
+			continue
+		}
+		var expected int64
+		if f := v.Field(version.Index); f.Kind() >= reflect.Uint && f.Kind() <= reflect.Uint64 {
+			expected = int64(f.Uint())
+		} else {
+			expected = f.Int()
+		}
+		if _, ok := groups[expected]; !ok {
+			versions = append(versions, expected)
+		}
+		groups[expected] = append(groups[expected], i)
+	}
+
+	for _, expected := range versions {
+		indices := groups[expected]
+		body := []byte("[")
+		for i, idx := range indices {
+			if i != 0 {
+				body = append(body, ',')
+			}
+			body = append(body, '[')
+			for j, cf := range cfs {
+				if j != 0 {
+					body = append(body, ',')
+				}
+				if cf == version {
+					body = AppendJSONInt(body, expected+1)
+				} else {
+					body = AppendJSONValue(body, vs[idx].Field(cf.Index))
+				}
+			}
+			body = append(body, ']')
+		}
+		body = append(body, ']')
+		options.IfExists = versionColumn + " == " + strconv.FormatInt(expected, 10)
+		loaded, err := db.LoadIDs(tbl, bytes.NewReader(body), options)
+		if err != nil {
+			return result, err
+		}
+		if len(loaded.LoadedIDs) != len(indices) {
+			return result, NewError(ResponseError, "The number of loaded IDs is wrong.", map[string]interface{}{
+				"ids":  loaded.LoadedIDs,
+				"rows": len(indices),
+			})
+		}
+		for i, idx := range indices {
+			id := loaded.LoadedIDs[i]
+			result.IDs[idx] = id
+			if id == 0 {
+				result.Skipped = append(result.Skipped, idx)
+				continue
+			}
+			result.Applied = append(result.Applied, idx)
+			if f := vs[idx].Field(version.Index); f.CanSet() {
+				if f.Kind() >= reflect.Uint && f.Kind() <= reflect.Uint64 {
+					f.SetUint(uint64(expected + 1))
+				} else {
+					f.SetInt(expected + 1)
+				}
+			}
+		}
+	}
+	sort.Ints(result.Applied)
+	sort.Ints(result.Skipped)
+	return result, nil
+}
+
+// casValue returns the canonical JSON of a value to compare keys.
+func casValue(data []byte) (string, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return "", NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	data, _ = json.Marshal(v)
+	return string(data), nil
+}
+
+// casExists returns whether or not the records of vs exist.
+func (db *DB) casExists(tbl string, key *ColumnField, vs []reflect.Value) ([]bool, error) {
+	exists := make([]bool, len(vs))
+	if len(vs) == 0 {
+		return exists, nil
+	}
+	keys := make([]string, len(vs))
+	filter := []byte("in_values(" + key.Name)
+	for i, v := range vs {
+		value := AppendJSONValue(nil, v.Field(key.Index))
+		filter = append(filter, ", "...)
+		filter = append(filter, value...)
+		var err error
+		if keys[i], err = casValue(value); err != nil {
+			return nil, err
+		}
+	}
+	filter = append(filter, ')')
+	options := NewDBSelectOptions()
+	options.Filter = string(filter)
+	options.OutputColumns = []string{key.Name}
+	options.Limit = len(vs)
+	resp, err := db.Select(tbl, options)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+	data, err := ioutil.ReadAll(resp)
+	if err != nil {
+		return nil, err
+	}
+	var raw [][][]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	if len(raw) == 0 || len(raw[0]) < 2 {
+		return nil, NewError(ResponseError, "The response is empty.", nil)
+	}
+	found := make(map[string]bool)
+	for _, record := range raw[0][2:] {
+		if len(record) == 0 {
+			continue
+		}
+		k, err := casValue(record[0])
+		if err != nil {
+			return nil, err
+		}
+		found[k] = true
+	}
+	for i, k := range keys {
+		exists[i] = found[k]
+	}
+	return exists, nil
+}
+
+// Increment adds delta to column of the record associated with key.
+// If the record does not exist, it is created with the default value plus delta.
+func (db *DB) Increment(tbl string, key interface{}, column string, delta int64) error {
+	if err := checkColumnName(column); err != nil {
+		return err
+	}
+	body := []byte(`[{"_key":`)
+	body = AppendJSON(body, key)
+	body = append(body, "}]"...)
+	options := NewDBLoadOptions()
+	options.Each = column + " = " + column + " + " + strconv.FormatInt(delta, 10)
+	n, err := db.Load(tbl, bytes.NewReader(body), options)
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return NewError(CommandError, "The record is not loaded.", map[string]interface{}{
+			"table": tbl,
+			"key":   key,
+		})
+	}
+	return nil
+}
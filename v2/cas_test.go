@@ -0,0 +1,79 @@
+package grnci
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testCASRow struct {
+	Key     string `grnci:"_key"`
+	Value   string `grnci:"value"`
+	Version int    `grnci:"version"`
+}
+
+func TestDBCompareAndSet(t *testing.T) {
+	h := newTestHandler(func(cmd *Command, body string) (string, error) {
+		if cmd.Name() == "select" {
+			return `[[[3],[["_key","ShortText"]],["a"],["b"],["c"]]]`, nil
+		}
+		switch cmd.Params()["ifexists"] {
+		case "version == 1":
+			return `{"header":{"return_code":0},"body":{"n_loaded_records":2,"loaded_ids":[1,0]}}`, nil
+		default:
+			return `{"n_loaded_records":1,"loaded_ids":[3]}`, nil
+		}
+	})
+	db := NewDB(h)
+	rows := []testCASRow{
+		{Key: "a", Value: "x", Version: 1},
+		{Key: "b", Value: "y", Version: 1},
+		{Key: "c", Value: "z", Version: 5},
+		{Key: "d", Value: "w", Version: 1},
+	}
+	result, err := db.CompareAndSet("Tbl", &rows, "version")
+	if err != nil {
+		t.Fatalf("db.CompareAndSet failed: %v", err)
+	}
+	want := &DBCompareAndSetResult{
+		Applied: []int{0, 2},
+		Skipped: []int{1, 3},
+		IDs:     []uint32{1, 0, 3, 0},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Fatalf("db.CompareAndSet failed: actual = %#v, want = %#v", result, want)
+	}
+	if actual := []int{rows[0].Version, rows[1].Version, rows[2].Version, rows[3].Version}; !reflect.DeepEqual(actual, []int{2, 1, 6, 1}) {
+		t.Fatalf("db.CompareAndSet failed: versions = %v, want = %v", actual, []int{2, 1, 6, 1})
+	}
+	if actual, want := h.commands[0].Params()["filter"], `in_values(_key, "a", "b", "c", "d")`; actual != want {
+		t.Fatalf("db.CompareAndSet failed: filter = %s, want = %s", actual, want)
+	}
+	if len(h.commands) != 3 {
+		t.Fatalf("db.CompareAndSet failed: commands = %v", h.commands)
+	}
+	if actual, want := h.bodies[1], `[["a","x",2],["b","y",2]]`; actual != want {
+		t.Fatalf("db.CompareAndSet failed: body = %s, want = %s", actual, want)
+	}
+	if actual, want := h.commands[2].String(), "load --columns '_key,value,version' --command_version '3' --ifexists 'version == 5' --output_ids 'yes' --table 'Tbl'"; actual != want {
+		t.Fatalf("db.CompareAndSet failed: actual = %s, want = %s", actual, want)
+	}
+}
+
+func TestDBIncrement(t *testing.T) {
+	h := newTestHandler(func(cmd *Command, body string) (string, error) {
+		return "1", nil
+	})
+	db := NewDB(h)
+	if err := db.Increment("Counters", "a", "count", -2); err != nil {
+		t.Fatalf("db.Increment failed: %v", err)
+	}
+	if actual, want := h.commands[0].String(), "load --each 'count = count + -2' --table 'Counters'"; actual != want {
+		t.Fatalf("db.Increment failed: actual = %s, want = %s", actual, want)
+	}
+	if actual, want := h.bodies[0], `[{"_key":"a"}]`; actual != want {
+		t.Fatalf("db.Increment failed: body = %s, want = %s", actual, want)
+	}
+	if err := db.Increment("Counters", "a", "count; x", 1); err == nil {
+		t.Fatalf("db.Increment wrongly succeeded")
+	}
+}
@@ -0,0 +1,450 @@
+// Package cassette provides Handlers to record and replay interactions.
+//
+// Recorder wraps a Handler connected to a real server and records
+// commands and full responses into a Cassette.
+// Player serves the recorded responses without a server,
+// so that tests using DB can run deterministically.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/groonga/grnci/v2"
+)
+
+// Error is an encodable form of grnci.Error.
+type Error struct {
+	Code    int                    `json:"code"`
+	Message string                 `json:"message,omitempty"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
+// newError returns a new Error.
+// If err is not *grnci.Error, it is recorded as an UnexpectedError.
+func newError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	e, ok := err.(*grnci.Error)
+	if !ok {
+		e = grnci.NewError(grnci.UnexpectedError, err.Error(), nil)
+	}
+	return &Error{
+		Code:    int(e.Code),
+		Message: e.Message,
+		Data:    e.Data,
+	}
+}
+
+// error returns the recorded error as *grnci.Error.
+func (e *Error) error() error {
+	if e == nil {
+		return nil
+	}
+	return grnci.NewError(grnci.ErrorCode(e.Code), e.Message, e.Data)
+}
+
+// Interaction is a recorded pair of a command and its response.
+type Interaction struct {
+	Command  string        `json:"command"`             // Command without body
+	Body     string        `json:"body,omitempty"`      // Command body
+	Response string        `json:"response"`            // Response body
+	Start    time.Time     `json:"start"`               // Response.Start
+	Elapsed  time.Duration `json:"elapsed"`             // Response.Elapsed
+	Err      *Error        `json:"error,omitempty"`     // Response.Err or Response.Close
+	QueryErr *Error        `json:"query_err,omitempty"` // Error returned by Query
+}
+
+// Cassette stores recorded interactions.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// ReadCassette reads a JSON-encoded Cassette from r.
+func ReadCassette(r io.Reader) (*Cassette, error) {
+	var c Cassette
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return nil, grnci.NewError(grnci.InputError, "json.Decoder.Decode failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	return &c, nil
+}
+
+// Load reads a Cassette from the specified file.
+func Load(path string) (*Cassette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, grnci.NewError(grnci.InputError, "os.Open failed.", map[string]interface{}{
+			"path":  path,
+			"error": err.Error(),
+		})
+	}
+	defer f.Close()
+	return ReadCassette(f)
+}
+
+// WriteTo writes the JSON-encoded Cassette to w.
+func (c *Cassette) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return 0, grnci.NewError(grnci.OutputError, "json.MarshalIndent failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	n, err := w.Write(append(data, '\n'))
+	if err != nil {
+		return int64(n), grnci.NewError(grnci.OutputError, "io.Writer.Write failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	return int64(n), nil
+}
+
+// Save writes the Cassette to the specified file.
+func (c *Cassette) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return grnci.NewError(grnci.OutputError, "os.Create failed.", map[string]interface{}{
+			"path":  path,
+			"error": err.Error(),
+		})
+	}
+	if _, err := c.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return grnci.NewError(grnci.OutputError, "os.File.Close failed.", map[string]interface{}{
+			"path":  path,
+			"error": err.Error(),
+		})
+	}
+	return nil
+}
+
+// response is a replayed response.
+type response struct {
+	*bytes.Reader
+	start   time.Time
+	elapsed time.Duration
+	err     error
+}
+
+// newResponse returns a new response for i.
+func newResponse(i *Interaction) *response {
+	return &response{
+		Reader:  bytes.NewReader([]byte(i.Response)),
+		start:   i.Start,
+		elapsed: i.Elapsed,
+		err:     i.Err.error(),
+	}
+}
+
+// Start returns the recorded start time.
+func (r *response) Start() time.Time {
+	return r.start
+}
+
+// Elapsed returns the recorded elapsed time.
+func (r *response) Elapsed() time.Duration {
+	return r.elapsed
+}
+
+// Close does nothing.
+func (r *response) Close() error {
+	return nil
+}
+
+// Err returns the recorded error.
+func (r *response) Err() error {
+	return r.err
+}
+
+// readBody reads the whole body of cmd and replaces it with a new reader.
+func readBody(cmd *grnci.Command) (string, error) {
+	if cmd.Body() == nil {
+		return "", nil
+	}
+	body, err := ioutil.ReadAll(cmd.Body())
+	if err != nil {
+		return "", grnci.NewError(grnci.InputError, "ioutil.ReadAll failed.", map[string]interface{}{
+			"name":  cmd.Name(),
+			"error": err.Error(),
+		})
+	}
+	cmd.SetBody(bytes.NewReader(body))
+	return string(body), nil
+}
+
+// Recorder is a Handler to record interactions with the underlying Handler.
+type Recorder struct {
+	h        grnci.Handler
+	cassette *Cassette
+	mutex    sync.Mutex
+}
+
+// NewRecorder returns a new Recorder.
+func NewRecorder(h grnci.Handler) *Recorder {
+	return &Recorder{
+		h:        h,
+		cassette: &Cassette{},
+	}
+}
+
+// Exec parses cmd and sends the parsed command via Query.
+func (r *Recorder) Exec(cmd string, body io.Reader) (grnci.Response, error) {
+	command, err := grnci.ParseCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	command.SetBody(body)
+	return r.Query(command)
+}
+
+// Invoke assembles name and params into a command and sends it via Query.
+func (r *Recorder) Invoke(name string, params map[string]interface{}, body io.Reader) (grnci.Response, error) {
+	cmd, err := grnci.NewCommand(name, params)
+	if err != nil {
+		return nil, err
+	}
+	cmd.SetBody(body)
+	return r.Query(cmd)
+}
+
+// Query sends cmd to the underlying Handler, records the whole response and
+// returns a response which replays it.
+func (r *Recorder) Query(cmd *grnci.Command) (grnci.Response, error) {
+	body, err := readBody(cmd)
+	if err != nil {
+		return nil, err
+	}
+	i := &Interaction{
+		Command: cmd.String(),
+		Body:    body,
+	}
+	resp, err := r.h.Query(cmd)
+	if err != nil {
+		i.QueryErr = newError(err)
+		r.append(i)
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(resp)
+	if err != nil {
+		resp.Close()
+		return nil, grnci.NewError(grnci.InputError, "ioutil.ReadAll failed.", map[string]interface{}{
+			"name":  cmd.Name(),
+			"error": err.Error(),
+		})
+	}
+	err = resp.Close()
+	if err == nil {
+		err = resp.Err()
+	}
+	i.Response = string(data)
+	i.Start = resp.Start()
+	i.Elapsed = resp.Elapsed()
+	i.Err = newError(err)
+	r.append(i)
+	return newResponse(i), nil
+}
+
+// append appends i to the cassette.
+func (r *Recorder) append(i *Interaction) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, i)
+}
+
+// Close closes the underlying Handler.
+func (r *Recorder) Close() error {
+	return r.h.Close()
+}
+
+// Cassette returns a copy of the recorded interactions.
+func (r *Recorder) Cassette() *Cassette {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	interactions := make([]*Interaction, len(r.cassette.Interactions))
+	copy(interactions, r.cassette.Interactions)
+	return &Cassette{Interactions: interactions}
+}
+
+// Save writes the recorded interactions to the specified file.
+func (r *Recorder) Save(path string) error {
+	return r.Cassette().Save(path)
+}
+
+// TestingT is the subset of testing.TB used by Player.
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+// MatchMode specifies how to match commands with interactions.
+type MatchMode int
+
+const (
+	// MatchExact requires the recorded command to be the same as Command.String.
+	MatchExact MatchMode = iota
+	// MatchUnordered compares the name and parameters regardless of their order.
+	MatchUnordered
+)
+
+// PlayerOptions stores options for Player.
+type PlayerOptions struct {
+	Match        MatchMode // How to match commands
+	IgnoreParams []string  // Parameters ignored in matching (MatchUnordered only)
+	IgnoreBody   bool      // Whether or not bodies are ignored in matching
+	Repeat       bool      // Whether or not interactions can be replayed more than once
+	T            TestingT  // Reports unmatched commands if not nil
+}
+
+// NewPlayerOptions returns the default PlayerOptions.
+func NewPlayerOptions() *PlayerOptions {
+	return &PlayerOptions{
+		Match: MatchUnordered,
+	}
+}
+
+// Player is a Handler to replay recorded interactions.
+type Player struct {
+	interactions []*Interaction
+	keys         []string
+	used         []bool
+	options      *PlayerOptions
+	mutex        sync.Mutex
+}
+
+// NewPlayer returns a new Player.
+func NewPlayer(c *Cassette, options *PlayerOptions) (*Player, error) {
+	if options == nil {
+		options = NewPlayerOptions()
+	}
+	if options.Match == MatchExact && len(options.IgnoreParams) != 0 {
+		return nil, grnci.NewError(grnci.CommandError, "IgnoreParams is not available with MatchExact.", map[string]interface{}{
+			"ignoreParams": options.IgnoreParams,
+		})
+	}
+	p := &Player{
+		interactions: c.Interactions,
+		keys:         make([]string, len(c.Interactions)),
+		used:         make([]bool, len(c.Interactions)),
+		options:      options,
+	}
+	for j, i := range c.Interactions {
+		if options.Match == MatchExact {
+			p.keys[j] = i.Command
+			continue
+		}
+		cmd, err := grnci.ParseCommand(i.Command)
+		if err != nil {
+			return nil, err
+		}
+		p.keys[j] = p.key(cmd)
+	}
+	return p, nil
+}
+
+// key returns the string used to match cmd.
+func (p *Player) key(cmd *grnci.Command) string {
+	if p.options.Match == MatchExact {
+		return cmd.String()
+	}
+	ignored := make(map[string]bool)
+	for _, name := range p.options.IgnoreParams {
+		ignored[name] = true
+	}
+	params := cmd.Params()
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if !ignored[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	key := cmd.Name()
+	for _, k := range keys {
+		key += " --" + k + " " + strconv.Quote(params[k])
+	}
+	return key
+}
+
+// Exec parses cmd and replays the parsed command via Query.
+func (p *Player) Exec(cmd string, body io.Reader) (grnci.Response, error) {
+	command, err := grnci.ParseCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	command.SetBody(body)
+	return p.Query(command)
+}
+
+// Invoke assembles name and params into a command and replays it via Query.
+func (p *Player) Invoke(name string, params map[string]interface{}, body io.Reader) (grnci.Response, error) {
+	cmd, err := grnci.NewCommand(name, params)
+	if err != nil {
+		return nil, err
+	}
+	cmd.SetBody(body)
+	return p.Query(cmd)
+}
+
+// Query returns the recorded response of the first unused interaction which
+// matches cmd.
+// If there is no such interaction, Query reports it via PlayerOptions.T and
+// returns an error.
+func (p *Player) Query(cmd *grnci.Command) (grnci.Response, error) {
+	body, err := readBody(cmd)
+	if err != nil {
+		return nil, err
+	}
+	command := cmd.String()
+	key := p.key(cmd)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for j, i := range p.interactions {
+		if p.used[j] && !p.options.Repeat {
+			continue
+		}
+		if p.keys[j] != key || (!p.options.IgnoreBody && i.Body != body) {
+			continue
+		}
+		p.used[j] = true
+		if i.QueryErr != nil {
+			return nil, i.QueryErr.error()
+		}
+		return newResponse(i), nil
+	}
+	if p.options.T != nil {
+		p.options.T.Errorf("cassette: unmatched command: %s", command)
+	}
+	return nil, grnci.NewError(grnci.OperationError, "No interaction matched.", map[string]interface{}{
+		"command": command,
+	})
+}
+
+// Close does nothing.
+func (p *Player) Close() error {
+	return nil
+}
+
+// Unused returns the interactions which have not been replayed.
+func (p *Player) Unused() []*Interaction {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var unused []*Interaction
+	for j, i := range p.interactions {
+		if !p.used[j] {
+			unused = append(unused, i)
+		}
+	}
+	return unused
+}
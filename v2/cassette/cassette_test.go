@@ -0,0 +1,139 @@
+package cassette
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/groonga/grnci/v2"
+	"github.com/groonga/grnci/v2/dryrun"
+)
+
+// testT records reported errors.
+type testT struct {
+	errors []string
+}
+
+func (t *testT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRecorderPlayer(t *testing.T) {
+	options := dryrun.NewHandlerOptions()
+	options.Fixtures = map[string]string{
+		"status": `{"version":"7.0.0"}`,
+	}
+	r := NewRecorder(dryrun.NewHandler(options))
+	db := grnci.NewDB(r)
+	if _, err := db.Status(); err != nil {
+		t.Fatalf("db.Status failed: %v", err)
+	}
+	if _, err := db.Exec("load --table Tbl", bytes.NewReader([]byte(`[{"_key":"a"}]`))); err != nil {
+		t.Fatalf("db.Exec failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err := r.Cassette().WriteTo(&buf); err != nil {
+		t.Fatalf("c.WriteTo failed: %v", err)
+	}
+	c, err := ReadCassette(&buf)
+	if err != nil {
+		t.Fatalf("ReadCassette failed: %v", err)
+	}
+	if actual, want := len(c.Interactions), 2; actual != want {
+		t.Fatalf("ReadCassette failed: actual = %d, want = %d", actual, want)
+	}
+
+	tt := &testT{}
+	playerOptions := NewPlayerOptions()
+	playerOptions.T = tt
+	p, err := NewPlayer(c, playerOptions)
+	if err != nil {
+		t.Fatalf("NewPlayer failed: %v", err)
+	}
+	db = grnci.NewDB(p)
+	result, err := db.Status()
+	if err != nil {
+		t.Fatalf("db.Status failed: %v", err)
+	}
+	if actual, want := result.Version, "7.0.0"; actual != want {
+		t.Fatalf("db.Status failed: actual = %s, want = %s", actual, want)
+	}
+	if _, err := db.Exec("load --table Tbl", bytes.NewReader([]byte(`[{"_key":"b"}]`))); err == nil {
+		t.Fatalf("db.Exec wrongly succeeded")
+	}
+	if len(tt.errors) != 1 {
+		t.Fatalf("p.Query failed: errors = %v", tt.errors)
+	}
+	resp, err := db.Exec("load --table Tbl", bytes.NewReader([]byte(`[{"_key":"a"}]`)))
+	if err != nil {
+		t.Fatalf("db.Exec failed: %v", err)
+	}
+	if body, _ := ioutil.ReadAll(resp); string(body) != "1" {
+		t.Fatalf("db.Exec failed: actual = %s, want = %s", body, "1")
+	}
+	if unused := p.Unused(); len(unused) != 0 {
+		t.Fatalf("p.Unused failed: actual = %d, want = %d", len(unused), 0)
+	}
+}
+
+func TestPlayerIgnoreParams(t *testing.T) {
+	c := &Cassette{
+		Interactions: []*Interaction{
+			{Command: "select --query 'x' --table 'Tbl'", Response: "[]"},
+			{Command: "table_list", Err: &Error{Code: int(grnci.GroongaError), Message: "failed"}},
+		},
+	}
+	options := NewPlayerOptions()
+	options.IgnoreParams = []string{"query"}
+	p, err := NewPlayer(c, options)
+	if err != nil {
+		t.Fatalf("NewPlayer failed: %v", err)
+	}
+	if _, err := p.Exec("select Tbl --query y", nil); err != nil {
+		t.Fatalf("p.Exec failed: %v", err)
+	}
+	resp, err := p.Exec("table_list", nil)
+	if err != nil {
+		t.Fatalf("p.Exec failed: %v", err)
+	}
+	if e, ok := resp.Err().(*grnci.Error); !ok || e.Code != grnci.GroongaError {
+		t.Fatalf("resp.Err failed: actual = %v", resp.Err())
+	}
+}
+
+func TestPlayerMatch(t *testing.T) {
+	c := &Cassette{
+		Interactions: []*Interaction{
+			{Command: "select --table 'Tbl' --limit '1'", Response: "[]"},
+		},
+	}
+	p, err := NewPlayer(c, nil)
+	if err != nil {
+		t.Fatalf("NewPlayer failed: %v", err)
+	}
+	if _, err := p.Exec("select Tbl --limit 1", nil); err != nil {
+		t.Fatalf("p.Exec failed: %v", err)
+	}
+
+	options := NewPlayerOptions()
+	options.Match = MatchExact
+	if p, err = NewPlayer(c, options); err != nil {
+		t.Fatalf("NewPlayer failed: %v", err)
+	}
+	if _, err := p.Exec("select Tbl --limit 1", nil); err == nil {
+		t.Fatalf("p.Exec wrongly succeeded")
+	}
+	c.Interactions[0].Command = "select --limit '1' --table 'Tbl'"
+	if p, err = NewPlayer(c, options); err != nil {
+		t.Fatalf("NewPlayer failed: %v", err)
+	}
+	if _, err := p.Exec("select Tbl --limit 1", nil); err != nil {
+		t.Fatalf("p.Exec failed: %v", err)
+	}
+
+	options.IgnoreParams = []string{"limit"}
+	if _, err := NewPlayer(c, options); err == nil {
+		t.Fatalf("NewPlayer wrongly succeeded")
+	}
+}
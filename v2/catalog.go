@@ -0,0 +1,236 @@
+package grnci
+
+import (
+	"sort"
+)
+
+// ParamKind is the kind of a parameter formatter.
+type ParamKind int
+
+// Parameter formatter kinds.
+const (
+	ParamValue        = ParamKind(iota) // Plain value
+	ParamCSV                            // Comma-separated values
+	ParamFlags                          // Pipe-separated flags
+	ParamYesNo                          // yes or no
+	ParamBorder                         // include or exclude
+	ParamMatchColumns                   // "||"-separated columns
+)
+
+// String returns the name of k.
+func (k ParamKind) String() string {
+	switch k {
+	case ParamValue:
+		return "value"
+	case ParamCSV:
+		return "csv"
+	case ParamFlags:
+		return "flags"
+	case ParamYesNo:
+		return "yes_no"
+	case ParamBorder:
+		return "border"
+	case ParamMatchColumns:
+		return "match_columns"
+	default:
+		return "unknown"
+	}
+}
+
+// ParamCheck is the kind of a value check of a parameter.
+type ParamCheck int
+
+// Parameter value checks.
+const (
+	ParamCheckNone   = ParamCheck(iota) // No check
+	ParamCheckInt                       // Integer
+	ParamCheckFloat                     // Number
+	ParamCheckEnum                      // One of ParamInfo.Values
+	ParamCheckTable                     // Table name
+	ParamCheckColumn                    // Column name
+)
+
+// String returns the name of c.
+func (c ParamCheck) String() string {
+	switch c {
+	case ParamCheckNone:
+		return "none"
+	case ParamCheckInt:
+		return "int"
+	case ParamCheckFloat:
+		return "float"
+	case ParamCheckEnum:
+		return "enum"
+	case ParamCheckTable:
+		return "table"
+	case ParamCheckColumn:
+		return "column"
+	default:
+		return "unknown"
+	}
+}
+
+// ParamInfo describes a parameter of a command.
+type ParamInfo struct {
+	Name     string     // Parameter name
+	Required bool       // Whether or not the parameter is required
+	Kind     ParamKind  // Formatter kind
+	Check    ParamCheck // Value check
+	Values   []string   // Acceptable values for ParamCheckEnum
+}
+
+// CommandInfo describes a command.
+type CommandInfo struct {
+	Name           string       // Command name
+	Params         []*ParamInfo // Fixed parameters in order
+	VariableParams bool         // Whether or not variable keys such as --columns[NAME].* are accepted
+	Body           bool         // Whether or not the command takes a body
+	ReadOnly       bool         // Whether or not the command changes nothing
+	Mutating       bool         // Whether or not the command changes data or schema
+	Dangerous      bool         // Whether or not the command is administrative or destructive
+	Idempotent     bool         // Whether or not repeating the command is safe
+}
+
+// Param returns the parameter named name.
+// If there is no such parameter, Param returns nil.
+func (ci *CommandInfo) Param(name string) *ParamInfo {
+	for _, p := range ci.Params {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Command attributes.
+const (
+	attrReadOnly = 1 << iota
+	attrMutating
+	attrDangerous
+	attrIdempotent
+	attrBody
+
+	attrQuery = attrReadOnly | attrIdempotent
+)
+
+// commandAttrs stores semantic attributes of commands.
+var commandAttrs = map[string]int{
+	"cache_limit":            attrIdempotent,
+	"check":                  attrQuery,
+	"clearlock":              attrDangerous | attrIdempotent,
+	"column_copy":            attrMutating | attrIdempotent,
+	"column_create":          attrMutating,
+	"column_list":            attrQuery,
+	"column_remove":          attrMutating | attrDangerous,
+	"column_rename":          attrMutating,
+	"config_delete":          attrMutating,
+	"config_get":             attrQuery,
+	"config_set":             attrMutating | attrIdempotent,
+	"database_unmap":         attrDangerous | attrIdempotent,
+	"define_selector":        attrDangerous,
+	"defrag":                 attrIdempotent,
+	"delete":                 attrMutating,
+	"dump":                   attrQuery,
+	"index_column_diff":      attrQuery,
+	"io_flush":               attrIdempotent,
+	"load":                   attrMutating | attrBody,
+	"lock_acquire":           0,
+	"lock_clear":             attrDangerous | attrIdempotent,
+	"lock_release":           0,
+	"log_level":              attrIdempotent,
+	"log_put":                0,
+	"log_reopen":             attrIdempotent,
+	"logical_count":          attrQuery,
+	"logical_parameters":     attrIdempotent,
+	"logical_range_filter":   attrQuery,
+	"logical_select":         attrQuery,
+	"logical_shard_list":     attrQuery,
+	"logical_table_remove":   attrMutating | attrDangerous,
+	"normalize":              attrQuery,
+	"normalizer_list":        attrQuery,
+	"object_exist":           attrQuery,
+	"object_inspect":         attrQuery,
+	"object_list":            attrQuery,
+	"object_remove":          attrMutating | attrDangerous,
+	"object_set_visibility":  attrMutating | attrIdempotent,
+	"plugin_register":        attrDangerous | attrIdempotent,
+	"plugin_unregister":      attrDangerous,
+	"query_expand":           attrQuery,
+	"query_log_flags_add":    attrIdempotent,
+	"query_log_flags_get":    attrQuery,
+	"query_log_flags_remove": attrIdempotent,
+	"query_log_flags_set":    attrIdempotent,
+	"quit":                   0,
+	"range_filter":           attrQuery,
+	"reference_acquire":      0,
+	"reference_release":      0,
+	"register":               attrDangerous | attrIdempotent,
+	"reindex":                attrMutating | attrIdempotent,
+	"request_cancel":         attrDangerous,
+	"ruby_eval":              attrDangerous,
+	"ruby_load":              attrDangerous,
+	"schema":                 attrQuery,
+	"select":                 attrQuery,
+	"shutdown":               attrDangerous,
+	"status":                 attrQuery,
+	"suggest":                attrQuery,
+	"table_copy":             attrMutating,
+	"table_create":           attrMutating,
+	"table_list":             attrQuery,
+	"table_remove":           attrMutating | attrDangerous,
+	"table_rename":           attrMutating,
+	"table_tokenize":         attrQuery,
+	"thread_dump":            attrIdempotent,
+	"thread_limit":           attrDangerous | attrIdempotent,
+	"tokenize":               attrQuery,
+	"tokenizer_list":         attrQuery,
+	"truncate":               attrMutating | attrDangerous | attrIdempotent,
+}
+
+// newCommandInfo returns a new CommandInfo.
+func newCommandInfo(name string, cf *commandFormat) *CommandInfo {
+	attrs := commandAttrs[name]
+	ci := &CommandInfo{
+		Name:           name,
+		VariableParams: cf.format != nil,
+		Body:           attrs&attrBody != 0,
+		ReadOnly:       attrs&attrReadOnly != 0,
+		Mutating:       attrs&attrMutating != 0,
+		Dangerous:      attrs&attrDangerous != 0,
+		Idempotent:     attrs&attrIdempotent != 0,
+	}
+	for _, pf := range cf.params {
+		ci.Params = append(ci.Params, &ParamInfo{
+			Name:     pf.key,
+			Required: pf.required,
+			Kind:     pf.kind,
+			Check:    pf.checkBy,
+			Values:   append([]string(nil), pf.values...),
+		})
+	}
+	return ci
+}
+
+// Commands returns the available commands in name order.
+func Commands() []*CommandInfo {
+	names := make([]string, 0, len(commandFormats))
+	for name := range commandFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	cis := make([]*CommandInfo, len(names))
+	for i, name := range names {
+		cis[i] = newCommandInfo(name, commandFormats[name])
+	}
+	return cis
+}
+
+// CommandSpec returns the description of the command named name.
+// If there is no such command, CommandSpec returns nil.
+func CommandSpec(name string) *CommandInfo {
+	cf := getCommandFormat(name)
+	if cf == nil {
+		return nil
+	}
+	return newCommandInfo(name, cf)
+}
@@ -0,0 +1,68 @@
+package grnci
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommands(t *testing.T) {
+	cis := Commands()
+	if len(cis) != len(commandFormats) {
+		t.Fatalf("Commands failed: len = %d, want = %d", len(cis), len(commandFormats))
+	}
+	for i, ci := range cis {
+		if i != 0 && cis[i-1].Name >= ci.Name {
+			t.Fatalf("Commands failed: %s is before %s", cis[i-1].Name, ci.Name)
+		}
+		if _, ok := commandAttrs[ci.Name]; !ok {
+			t.Fatalf("Commands failed: no attributes: name = %s", ci.Name)
+		}
+		if ci.ReadOnly && (ci.Mutating || ci.Dangerous) {
+			t.Fatalf("Commands failed: inconsistent attributes: %#v", ci)
+		}
+	}
+}
+
+func TestCommandSpec(t *testing.T) {
+	ci := CommandSpec("logical_table_remove")
+	if ci == nil {
+		t.Fatalf("CommandSpec failed: logical_table_remove is not found")
+	}
+	if !ci.Mutating || !ci.Dangerous || ci.ReadOnly || ci.Body || ci.VariableParams {
+		t.Fatalf("CommandSpec failed: actual = %#v", ci)
+	}
+	data := map[string]struct {
+		required bool
+		kind     ParamKind
+		check    ParamCheck
+	}{
+		"logical_table": {true, ParamValue, ParamCheckTable},
+		"shard_key":     {true, ParamValue, ParamCheckNone},
+		"min_border":    {false, ParamBorder, ParamCheckEnum},
+		"force":         {false, ParamYesNo, ParamCheckEnum},
+	}
+	for name, want := range data {
+		p := ci.Param(name)
+		if p == nil || p.Required != want.required || p.Kind != want.kind || p.Check != want.check {
+			t.Fatalf("CommandSpec failed: name = %s, actual = %#v, want = %v", name, p, want)
+		}
+	}
+	if actual, want := strings.Join(ci.Param("force").Values, ","), "yes,no"; actual != want {
+		t.Fatalf("CommandSpec failed: actual = %s, want = %s", actual, want)
+	}
+	if p := CommandSpec("shutdown").Param("mode"); p.Check != ParamCheckEnum || len(p.Values) != 2 {
+		t.Fatalf("CommandSpec failed: actual = %#v", p)
+	}
+	if p := CommandSpec("select").Param("limit"); p.Check != ParamCheckInt {
+		t.Fatalf("CommandSpec failed: actual = %#v", p)
+	}
+	if ci := CommandSpec("load"); !ci.Body || ci.Param("columns").Kind != ParamCSV {
+		t.Fatalf("CommandSpec failed: actual = %#v", ci)
+	}
+	if ci := CommandSpec("select"); !ci.ReadOnly || !ci.VariableParams {
+		t.Fatalf("CommandSpec failed: actual = %#v", ci)
+	}
+	if ci := CommandSpec("no_such_command"); ci != nil {
+		t.Fatalf("CommandSpec failed: actual = %#v, want = nil", ci)
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net/url"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // commandSpaces is a set of characters handled as spaces in commands.
@@ -31,6 +33,11 @@ func formatParamValue(key string, value interface{}) (string, error) {
 		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
 	case reflect.String:
 		return v.String(), nil
+	case reflect.Struct:
+		if t, ok := value.(time.Time); ok {
+			return string(AppendJSONTime(nil, t)), nil
+		}
+		fallthrough
 	default:
 		return "", NewError(CommandError, "The type is not supported.", map[string]interface{}{
 			"key":   key,
@@ -118,19 +125,124 @@ func formatParamMatchColumns(key string, value interface{}) (string, error) {
 	return formatParamDelim(key, value, "||")
 }
 
+// checkParam is a function to check a formatted parameter value.
+type checkParam func(value string) error
+
+// checkParamInt checks if value is an integer.
+func checkParamInt(value string) error {
+	if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+		return NewError(CommandError, "The value must be an integer.", nil)
+	}
+	return nil
+}
+
+// checkParamFloat checks if value is a number.
+func checkParamFloat(value string) error {
+	if _, err := strconv.ParseFloat(value, 64); err != nil {
+		return NewError(CommandError, "The value must be a number.", nil)
+	}
+	return nil
+}
+
+// checkParamEnum returns a checkParam which accepts only values.
+// The values are compared in a case-insensitive manner.
+func checkParamEnum(values ...string) checkParam {
+	return func(value string) error {
+		for _, v := range values {
+			if strings.EqualFold(value, v) {
+				return nil
+			}
+		}
+		return NewError(CommandError, fmt.Sprintf("The value must be one of %s.", strings.Join(values, ", ")), nil)
+	}
+}
+
 type paramFormat struct {
 	key      string      // Parameter key
+	kind     ParamKind   // Formatter kind
 	format   formatParam // Custom function to format a parameter.
 	required bool        // Whether or not the parameter is required
+	check    checkParam  // Custom function to check a formatted parameter
+	checkBy  ParamCheck  // Kind of check
+	values   []string    // Acceptable values for ParamCheckEnum
 }
 
 // newParamFormat returns a new paramFormat.
-func newParamFormat(key string, format formatParam, required bool) *paramFormat {
-	return &paramFormat{
+func newParamFormat(key string, kind ParamKind, required bool) *paramFormat {
+	pf := &paramFormat{
 		key:      key,
-		format:   format,
+		kind:     kind,
 		required: required,
 	}
+	switch kind {
+	case ParamCSV:
+		pf.format = formatParamCSV
+	case ParamFlags:
+		pf.format = formatParamFlags
+	case ParamYesNo:
+		pf.format = formatParamYesNo
+		pf.setEnum("yes", "no")
+	case ParamBorder:
+		pf.format = formatParamBorder
+		pf.setEnum("include", "exclude")
+	case ParamMatchColumns:
+		pf.format = formatParamMatchColumns
+	}
+	return pf
+}
+
+// setEnum makes pf accept only values.
+func (pf *paramFormat) setEnum(values ...string) {
+	pf.check = checkParamEnum(values...)
+	pf.checkBy = ParamCheckEnum
+	pf.values = values
+}
+
+// newIntParamFormat returns a new paramFormat for an integer.
+func newIntParamFormat(key string, required bool) *paramFormat {
+	pf := newParamFormat(key, ParamValue, required)
+	pf.check = checkParamInt
+	pf.checkBy = ParamCheckInt
+	return pf
+}
+
+// newFloatParamFormat returns a new paramFormat for a number.
+func newFloatParamFormat(key string, required bool) *paramFormat {
+	pf := newParamFormat(key, ParamValue, required)
+	pf.check = checkParamFloat
+	pf.checkBy = ParamCheckFloat
+	return pf
+}
+
+// newEnumParamFormat returns a new paramFormat for one of values.
+func newEnumParamFormat(key string, required bool, values ...string) *paramFormat {
+	pf := newParamFormat(key, ParamValue, required)
+	pf.setEnum(values...)
+	return pf
+}
+
+// newTableParamFormat returns a new paramFormat for a table name.
+func newTableParamFormat(key string, required bool) *paramFormat {
+	pf := newParamFormat(key, ParamValue, required)
+	pf.check = checkTableName
+	pf.checkBy = ParamCheckTable
+	return pf
+}
+
+// newColumnParamFormat returns a new paramFormat for a column name.
+func newColumnParamFormat(key string, required bool) *paramFormat {
+	pf := newParamFormat(key, ParamValue, required)
+	pf.check = checkColumnName
+	pf.checkBy = ParamCheckColumn
+	return pf
+}
+
+// Check checks a formatted parameter value.
+func (pf *paramFormat) Check(value string) error {
+	if pf.check == nil {
+		return nil
+	}
+	return pf.check(value)
 }
 
 // Format formats a parameter.
@@ -190,10 +302,12 @@ func formatParamSelect(key string, value interface{}) (string, error) {
 			}
 		}
 	}
-	// For parameters with variable keys, such as --columns[NAME] and --drilldowns[LABEL].
+	// For parameters with variable keys, such as --columns[NAME], --drilldowns[LABEL] and --slices[LABEL].
 	switch {
 	case strings.HasSuffix(key, "flags"):
 		return formatParamFlags(key, value)
+	case strings.HasSuffix(key, "match_columns"):
+		return formatParamMatchColumns(key, value)
 	case strings.HasSuffix(key, "keys"), // keys, sort_keys and group_keys
 		strings.HasSuffix(key, "output_columns"),
 		strings.HasSuffix(key, "calc_types"):
@@ -250,356 +364,415 @@ func (cf *commandFormat) Format(key string, value interface{}) (string, error) {
 var commandFormats = map[string]*commandFormat{
 	"cache_limit": newCommandFormat(
 		nil,
-		newParamFormat("max", nil, false),
+		newIntParamFormat("max", false),
 	),
 	"check": newCommandFormat(
 		nil,
-		newParamFormat("obj", nil, true),
+		newParamFormat("obj", ParamValue, true),
 	),
 	"clearlock": newCommandFormat(
 		nil,
-		newParamFormat("objname", nil, true),
+		newParamFormat("objname", ParamValue, true),
 	),
 	"column_copy": newCommandFormat(
 		nil,
-		newParamFormat("from_table", nil, true),
-		newParamFormat("from_name", nil, true),
-		newParamFormat("to_table", nil, true),
-		newParamFormat("to_name", nil, true),
+		newParamFormat("from_table", ParamValue, true),
+		newParamFormat("from_name", ParamValue, true),
+		newParamFormat("to_table", ParamValue, true),
+		newParamFormat("to_name", ParamValue, true),
 	),
 	"column_create": newCommandFormat(
 		nil,
-		newParamFormat("table", nil, true),
-		newParamFormat("name", nil, true),
-		newParamFormat("flags", formatParamFlags, true),
-		newParamFormat("type", nil, true),
-		newParamFormat("source", formatParamCSV, false),
+		newTableParamFormat("table", true),
+		newColumnParamFormat("name", true),
+		newParamFormat("flags", ParamFlags, true),
+		newParamFormat("type", ParamValue, true),
+		newParamFormat("source", ParamCSV, false),
+		newParamFormat("path", ParamValue, false),
 	),
 	"column_list": newCommandFormat(
 		nil,
-		newParamFormat("table", nil, true),
+		newTableParamFormat("table", true),
 	),
 	"column_remove": newCommandFormat(nil,
-		newParamFormat("table", nil, true),
-		newParamFormat("name", nil, true),
+		newTableParamFormat("table", true),
+		newColumnParamFormat("name", true),
 	),
 	"column_rename": newCommandFormat(nil,
-		newParamFormat("table", nil, true),
-		newParamFormat("name", nil, true),
-		newParamFormat("new_name", nil, true),
+		newTableParamFormat("table", true),
+		newColumnParamFormat("name", true),
+		newColumnParamFormat("new_name", true),
 	),
 	"config_delete": newCommandFormat(
 		nil,
-		newParamFormat("key", nil, true),
+		newParamFormat("key", ParamValue, true),
 	),
 	"config_get": newCommandFormat(
 		nil,
-		newParamFormat("key", nil, true),
+		newParamFormat("key", ParamValue, true),
 	),
 	"config_set": newCommandFormat(
 		nil,
-		newParamFormat("key", nil, true),
-		newParamFormat("value", nil, true),
+		newParamFormat("key", ParamValue, true),
+		newParamFormat("value", ParamValue, true),
 	),
 	"database_unmap": newCommandFormat(nil),
 	"define_selector": newCommandFormat(
 		nil,
-		newParamFormat("name", nil, true),
-		newParamFormat("table", nil, true),
-		newParamFormat("match_columns", formatParamMatchColumns, false),
-		newParamFormat("query", nil, false),
-		newParamFormat("filter", nil, false),
-		newParamFormat("scorer", nil, false),
-		newParamFormat("sortby", formatParamCSV, false),
-		newParamFormat("output_columns", formatParamCSV, false),
-		newParamFormat("offset", nil, false),
-		newParamFormat("limit", nil, false),
-		newParamFormat("drilldown", formatParamCSV, false),
-		newParamFormat("drilldown_sortby", formatParamCSV, false),
-		newParamFormat("drilldown_output_columns", formatParamCSV, false),
-		newParamFormat("drilldown_offset", nil, false),
-		newParamFormat("drilldown_limit", nil, false),
+		newParamFormat("name", ParamValue, true),
+		newTableParamFormat("table", true),
+		newParamFormat("match_columns", ParamMatchColumns, false),
+		newParamFormat("query", ParamValue, false),
+		newParamFormat("filter", ParamValue, false),
+		newParamFormat("scorer", ParamValue, false),
+		newParamFormat("sortby", ParamCSV, false),
+		newParamFormat("output_columns", ParamCSV, false),
+		newIntParamFormat("offset", false),
+		newIntParamFormat("limit", false),
+		newParamFormat("drilldown", ParamCSV, false),
+		newParamFormat("drilldown_sortby", ParamCSV, false),
+		newParamFormat("drilldown_output_columns", ParamCSV, false),
+		newIntParamFormat("drilldown_offset", false),
+		newIntParamFormat("drilldown_limit", false),
 	),
 	"defrag": newCommandFormat(
 		nil,
-		newParamFormat("objname", nil, true),
-		newParamFormat("threshold", nil, true),
+		newParamFormat("objname", ParamValue, true),
+		newIntParamFormat("threshold", true),
 	),
 	"delete": newCommandFormat(
 		nil,
-		newParamFormat("table", nil, true),
-		newParamFormat("key", nil, false),
-		newParamFormat("id", nil, false),
-		newParamFormat("filter", nil, false),
+		newTableParamFormat("table", true),
+		newParamFormat("key", ParamValue, false),
+		newIntParamFormat("id", false),
+		newParamFormat("filter", ParamValue, false),
 	),
 	"dump": newCommandFormat(
 		nil,
-		newParamFormat("tables", formatParamCSV, false),
-		newParamFormat("dump_plugins", formatParamYesNo, false),
-		newParamFormat("dump_schema", formatParamYesNo, false),
-		newParamFormat("dump_records", formatParamYesNo, false),
-		newParamFormat("dump_indexes", formatParamYesNo, false),
-		newParamFormat("dump_configs", formatParamYesNo, false),
-		newParamFormat("sort_hash_table", formatParamYesNo, false),
+		newParamFormat("tables", ParamCSV, false),
+		newParamFormat("dump_plugins", ParamYesNo, false),
+		newParamFormat("dump_schema", ParamYesNo, false),
+		newParamFormat("dump_records", ParamYesNo, false),
+		newParamFormat("dump_indexes", ParamYesNo, false),
+		newParamFormat("dump_configs", ParamYesNo, false),
+		newParamFormat("sort_hash_table", ParamYesNo, false),
+	),
+	"index_column_diff": newCommandFormat(
+		nil,
+		newTableParamFormat("table", true),
+		newColumnParamFormat("name", true),
 	),
 	"io_flush": newCommandFormat(
 		nil,
-		newParamFormat("target_name", nil, false),
-		newParamFormat("recursive", formatParamYesNo, false),
-		newParamFormat("only_opened", formatParamYesNo, false),
+		newParamFormat("target_name", ParamValue, false),
+		newParamFormat("recursive", ParamYesNo, false),
+		newParamFormat("only_opened", ParamYesNo, false),
 	),
 	"load": newCommandFormat(
 		nil,
-		newParamFormat("values", nil, false), // values may be passed as a body.
-		newParamFormat("table", nil, true),
-		newParamFormat("columns", formatParamCSV, false),
-		newParamFormat("ifexists", nil, false),
-		newParamFormat("input_type", nil, false),
+		newParamFormat("values", ParamValue, false), // values may be passed as a body.
+		newTableParamFormat("table", true),
+		newParamFormat("columns", ParamCSV, false),
+		newParamFormat("ifexists", ParamValue, false),
+		newEnumParamFormat("input_type", false, "json", "apache-arrow"),
+		newParamFormat("each", ParamValue, false),
+		newParamFormat("output_ids", ParamYesNo, false),
+		newParamFormat("output_errors", ParamYesNo, false),
+		newParamFormat("lock_table", ParamYesNo, false),
 	),
 	"lock_acquire": newCommandFormat(
 		nil,
-		newParamFormat("target_name", nil, false),
+		newParamFormat("target_name", ParamValue, false),
 	),
 	"lock_clear": newCommandFormat(
 		nil,
-		newParamFormat("target_name", nil, false),
+		newParamFormat("target_name", ParamValue, false),
 	),
 	"lock_release": newCommandFormat(
 		nil,
-		newParamFormat("target_name", nil, false),
+		newParamFormat("target_name", ParamValue, false),
 	),
 	"log_level": newCommandFormat(
 		nil,
-		newParamFormat("level", nil, true),
+		newParamFormat("level", ParamValue, true),
 	),
 	"log_put": newCommandFormat(
 		nil,
-		newParamFormat("level", nil, true),
-		newParamFormat("message", nil, true),
+		newParamFormat("level", ParamValue, true),
+		newParamFormat("message", ParamValue, true),
 	),
 	"log_reopen": newCommandFormat(nil),
 	"logical_count": newCommandFormat(
 		nil,
-		newParamFormat("logical_table", nil, true),
-		newParamFormat("shard_key", nil, true),
-		newParamFormat("min", nil, false),
-		newParamFormat("min_border", formatParamBorder, false),
-		newParamFormat("max", nil, false),
-		newParamFormat("max_border", formatParamBorder, false),
-		newParamFormat("filter", nil, false),
+		newTableParamFormat("logical_table", true),
+		newParamFormat("shard_key", ParamValue, true),
+		newParamFormat("min", ParamValue, false),
+		newParamFormat("min_border", ParamBorder, false),
+		newParamFormat("max", ParamValue, false),
+		newParamFormat("max_border", ParamBorder, false),
+		newParamFormat("filter", ParamValue, false),
 	),
 	"logical_parameters": newCommandFormat(
 		nil,
-		newParamFormat("range_index", nil, false),
+		newEnumParamFormat("range_index", false, "auto", "always", "never"),
 	),
 	"logical_range_filter": newCommandFormat(
 		nil,
-		newParamFormat("logical_table", nil, true),
-		newParamFormat("shard_key", nil, true),
-		newParamFormat("min", nil, false),
-		newParamFormat("min_border", formatParamBorder, false),
-		newParamFormat("max", nil, false),
-		newParamFormat("max_border", formatParamBorder, false),
-		newParamFormat("order", nil, false),
-		newParamFormat("filter", nil, false),
-		newParamFormat("offset", nil, false),
-		newParamFormat("limit", nil, false),
-		newParamFormat("output_columns", formatParamCSV, false),
-		newParamFormat("use_range_index", nil, false),
-		// TODO: --cache is not supported yet.
+		newTableParamFormat("logical_table", true),
+		newParamFormat("shard_key", ParamValue, true),
+		newParamFormat("min", ParamValue, false),
+		newParamFormat("min_border", ParamBorder, false),
+		newParamFormat("max", ParamValue, false),
+		newParamFormat("max_border", ParamBorder, false),
+		newEnumParamFormat("order", false, "ascending", "descending"),
+		newParamFormat("filter", ParamValue, false),
+		newIntParamFormat("offset", false),
+		newIntParamFormat("limit", false),
+		newParamFormat("output_columns", ParamCSV, false),
+		newEnumParamFormat("use_range_index", false, "auto", "always", "never"),
+		newParamFormat("cache", ParamYesNo, false),
 	),
 	"logical_select": newCommandFormat(
 		formatParamSelect,
-		newParamFormat("logical_table", nil, true),
-		newParamFormat("shard_key", nil, true),
-		newParamFormat("min", nil, false),
-		newParamFormat("min_border", formatParamBorder, false),
-		newParamFormat("max", nil, false),
-		newParamFormat("max_border", formatParamBorder, false),
-		newParamFormat("filter", nil, false),
-		newParamFormat("sortby", formatParamCSV, false),
-		newParamFormat("output_columns", formatParamCSV, false),
-		newParamFormat("offset", nil, false),
-		newParamFormat("limit", nil, false),
-		newParamFormat("drilldown", nil, false),
-		newParamFormat("drilldown_sortby", formatParamCSV, false),
-		newParamFormat("drilldown_output_columns", formatParamCSV, false),
-		newParamFormat("drilldown_offset", nil, false),
-		newParamFormat("drilldown_limit", nil, false),
-		newParamFormat("drilldown_calc_types", formatParamCSV, false),
-		newParamFormat("drilldown_calc_target", nil, false),
-		newParamFormat("sort_keys", formatParamCSV, false),
-		newParamFormat("drilldown_sort_keys", formatParamCSV, false),
-		newParamFormat("match_columns", formatParamMatchColumns, false),
-		newParamFormat("query", nil, false),
-		newParamFormat("drilldown_filter", nil, false),
+		newTableParamFormat("logical_table", true),
+		newParamFormat("shard_key", ParamValue, true),
+		newParamFormat("min", ParamValue, false),
+		newParamFormat("min_border", ParamBorder, false),
+		newParamFormat("max", ParamValue, false),
+		newParamFormat("max_border", ParamBorder, false),
+		newParamFormat("filter", ParamValue, false),
+		newParamFormat("sortby", ParamCSV, false),
+		newParamFormat("output_columns", ParamCSV, false),
+		newIntParamFormat("offset", false),
+		newIntParamFormat("limit", false),
+		newParamFormat("drilldown", ParamCSV, false),
+		newParamFormat("drilldown_sortby", ParamCSV, false),
+		newParamFormat("drilldown_output_columns", ParamCSV, false),
+		newIntParamFormat("drilldown_offset", false),
+		newIntParamFormat("drilldown_limit", false),
+		newParamFormat("drilldown_calc_types", ParamCSV, false),
+		newParamFormat("drilldown_calc_target", ParamValue, false),
+		newParamFormat("sort_keys", ParamCSV, false),
+		newParamFormat("drilldown_sort_keys", ParamCSV, false),
+		newParamFormat("match_columns", ParamMatchColumns, false),
+		newParamFormat("query", ParamValue, false),
+		newParamFormat("drilldown_filter", ParamValue, false),
 	),
 	"logical_shard_list": newCommandFormat(
 		nil,
-		newParamFormat("logical_table", nil, true),
+		newTableParamFormat("logical_table", true),
 	),
 	"logical_table_remove": newCommandFormat(
 		nil,
-		newParamFormat("logical_table", nil, true),
-		newParamFormat("shard_key", nil, true),
-		newParamFormat("min", nil, false),
-		newParamFormat("min_border", formatParamBorder, false),
-		newParamFormat("max", nil, false),
-		newParamFormat("max_border", formatParamBorder, false),
-		newParamFormat("dependent", formatParamYesNo, false),
-		newParamFormat("force", formatParamYesNo, false),
+		newTableParamFormat("logical_table", true),
+		newParamFormat("shard_key", ParamValue, true),
+		newParamFormat("min", ParamValue, false),
+		newParamFormat("min_border", ParamBorder, false),
+		newParamFormat("max", ParamValue, false),
+		newParamFormat("max_border", ParamBorder, false),
+		newParamFormat("dependent", ParamYesNo, false),
+		newParamFormat("force", ParamYesNo, false),
 	),
 	"normalize": newCommandFormat(
 		nil,
-		newParamFormat("normalizer", nil, true),
-		newParamFormat("string", nil, true),
-		newParamFormat("flags", formatParamFlags, false),
+		newParamFormat("normalizer", ParamValue, true),
+		newParamFormat("string", ParamValue, true),
+		newParamFormat("flags", ParamFlags, false),
 	),
 	"normalizer_list": newCommandFormat(nil),
 	"object_exist": newCommandFormat(
 		nil,
-		newParamFormat("name", nil, true),
+		newParamFormat("name", ParamValue, true),
 	),
 	"object_inspect": newCommandFormat(
 		nil,
-		newParamFormat("name", nil, false),
+		newParamFormat("name", ParamValue, false),
 	),
 	"object_list": newCommandFormat(nil),
 	"object_remove": newCommandFormat(
 		nil,
-		newParamFormat("name", nil, true),
-		newParamFormat("force", formatParamYesNo, false),
+		newParamFormat("name", ParamValue, true),
+		newParamFormat("force", ParamYesNo, false),
+	),
+	"object_set_visibility": newCommandFormat(
+		nil,
+		newParamFormat("name", ParamValue, true),
+		newParamFormat("visible", ParamYesNo, true),
 	),
 	"plugin_register": newCommandFormat(
 		nil,
-		newParamFormat("name", nil, true),
+		newParamFormat("name", ParamValue, true),
 	),
 	"plugin_unregister": newCommandFormat(
 		nil,
-		newParamFormat("name", nil, true),
+		newParamFormat("name", ParamValue, true),
+	),
+	"query_expand": newCommandFormat(
+		nil,
+		newParamFormat("expander", ParamValue, true),
+		newParamFormat("query", ParamValue, true),
+		newParamFormat("flags", ParamFlags, false),
+		newParamFormat("term_column", ParamValue, false),
+		newParamFormat("expanded_term_column", ParamValue, false),
+	),
+	"query_log_flags_add": newCommandFormat(
+		nil,
+		newParamFormat("flags", ParamFlags, true),
+	),
+	"query_log_flags_get": newCommandFormat(nil),
+	"query_log_flags_remove": newCommandFormat(
+		nil,
+		newParamFormat("flags", ParamFlags, true),
+	),
+	"query_log_flags_set": newCommandFormat(
+		nil,
+		newParamFormat("flags", ParamFlags, true),
+	),
+	"quit": newCommandFormat(nil),
+	"range_filter": newCommandFormat(
+		nil,
+		newTableParamFormat("table", true),
+		newParamFormat("column", ParamValue, true),
+		newParamFormat("min", ParamValue, false),
+		newParamFormat("min_border", ParamBorder, false),
+		newParamFormat("max", ParamValue, false),
+		newParamFormat("max_border", ParamBorder, false),
+		newIntParamFormat("offset", false),
+		newIntParamFormat("limit", false),
+		newParamFormat("filter", ParamValue, false),
+		newParamFormat("output_columns", ParamCSV, false),
+	),
+	"reference_acquire": newCommandFormat(
+		nil,
+		newParamFormat("target_name", ParamValue, false),
+		newEnumParamFormat("recursive", false, "yes", "no", "dependent"),
+	),
+	"reference_release": newCommandFormat(
+		nil,
+		newParamFormat("target_name", ParamValue, false),
+		newEnumParamFormat("recursive", false, "yes", "no", "dependent"),
 	),
-	"query_expand": newCommandFormat(nil), // TODO: not documented.
-	"quit":         newCommandFormat(nil),
-	"range_filter": newCommandFormat(nil), // TODO: not documented.
 	"register": newCommandFormat(
 		nil,
-		newParamFormat("path", nil, true),
+		newParamFormat("path", ParamValue, true),
 	),
 	"reindex": newCommandFormat(
 		nil,
-		newParamFormat("target_name", nil, false),
+		newParamFormat("target_name", ParamValue, false),
 	),
 	"request_cancel": newCommandFormat(
 		nil,
-		newParamFormat("id", nil, true),
+		newParamFormat("id", ParamValue, true),
 	),
 	"ruby_eval": newCommandFormat(
 		nil,
-		newParamFormat("script", nil, true),
+		newParamFormat("script", ParamValue, true),
 	),
 	"ruby_load": newCommandFormat(
 		nil,
-		newParamFormat("path", nil, true),
+		newParamFormat("path", ParamValue, true),
 	),
 	"schema": newCommandFormat(nil),
 	"select": newCommandFormat(
 		formatParamSelect,
-		newParamFormat("table", nil, true),
-		newParamFormat("match_columns", formatParamMatchColumns, false),
-		newParamFormat("query", nil, false),
-		newParamFormat("filter", nil, false),
-		newParamFormat("scorer", nil, false),
-		newParamFormat("sortby", formatParamCSV, false),
-		newParamFormat("output_columns", formatParamCSV, false),
-		newParamFormat("offset", nil, false),
-		newParamFormat("limit", nil, false),
-		newParamFormat("drilldown", nil, false),
-		newParamFormat("drilldown_sortby", formatParamCSV, false),
-		newParamFormat("drilldown_output_columns", formatParamCSV, false),
-		newParamFormat("drilldown_offset", nil, false),
-		newParamFormat("drilldown_limit", nil, false),
-		newParamFormat("cache", formatParamYesNo, false),
-		newParamFormat("match_escalation_threshold", nil, false),
-		newParamFormat("query_expansion", nil, false),
-		newParamFormat("query_flags", formatParamFlags, false),
-		newParamFormat("query_expander", nil, false),
-		newParamFormat("adjuster", nil, false),
-		newParamFormat("drilldown_calc_types", formatParamCSV, false),
-		newParamFormat("drilldown_calc_target", nil, false),
-		newParamFormat("drilldown_filter", nil, false),
-		newParamFormat("sort_keys", formatParamCSV, false),
-		newParamFormat("drilldown_sort_keys", formatParamCSV, false),
+		newTableParamFormat("table", true),
+		newParamFormat("match_columns", ParamMatchColumns, false),
+		newParamFormat("query", ParamValue, false),
+		newParamFormat("filter", ParamValue, false),
+		newParamFormat("scorer", ParamValue, false),
+		newParamFormat("sortby", ParamCSV, false),
+		newParamFormat("output_columns", ParamCSV, false),
+		newIntParamFormat("offset", false),
+		newIntParamFormat("limit", false),
+		newParamFormat("drilldown", ParamCSV, false),
+		newParamFormat("drilldown_sortby", ParamCSV, false),
+		newParamFormat("drilldown_output_columns", ParamCSV, false),
+		newIntParamFormat("drilldown_offset", false),
+		newIntParamFormat("drilldown_limit", false),
+		newParamFormat("cache", ParamYesNo, false),
+		newIntParamFormat("match_escalation_threshold", false),
+		newParamFormat("query_expansion", ParamValue, false),
+		newParamFormat("query_flags", ParamFlags, false),
+		newParamFormat("query_expander", ParamValue, false),
+		newParamFormat("adjuster", ParamValue, false),
+		newParamFormat("drilldown_calc_types", ParamCSV, false),
+		newParamFormat("drilldown_calc_target", ParamValue, false),
+		newParamFormat("drilldown_filter", ParamValue, false),
+		newParamFormat("sort_keys", ParamCSV, false),
+		newParamFormat("drilldown_sort_keys", ParamCSV, false),
 	),
 	"shutdown": newCommandFormat(
 		nil,
-		newParamFormat("mode", nil, false),
+		newEnumParamFormat("mode", false, "graceful", "immediate"),
 	),
 	"status": newCommandFormat(nil),
 	"suggest": newCommandFormat(
 		nil,
-		newParamFormat("types", formatParamFlags, true),
-		newParamFormat("table", nil, true),
-		newParamFormat("column", nil, true),
-		newParamFormat("query", nil, true),
-		newParamFormat("sortby", formatParamCSV, false),
-		newParamFormat("output_columns", formatParamCSV, false),
-		newParamFormat("offset", nil, false),
-		newParamFormat("limit", nil, false),
-		newParamFormat("frequency_threshold", nil, false),
-		newParamFormat("conditional_probability_threshold", nil, false),
-		newParamFormat("prefix_search", nil, false),
+		newParamFormat("types", ParamFlags, true),
+		newTableParamFormat("table", true),
+		newParamFormat("column", ParamValue, true),
+		newParamFormat("query", ParamValue, true),
+		newParamFormat("sortby", ParamCSV, false),
+		newParamFormat("output_columns", ParamCSV, false),
+		newIntParamFormat("offset", false),
+		newIntParamFormat("limit", false),
+		newIntParamFormat("frequency_threshold", false),
+		newFloatParamFormat("conditional_probability_threshold", false),
+		newEnumParamFormat("prefix_search", false, "yes", "no", "auto"),
 	),
 	"table_copy": newCommandFormat(
 		nil,
-		newParamFormat("from_name", nil, true),
-		newParamFormat("to_name", nil, true),
+		newTableParamFormat("from_name", true),
+		newTableParamFormat("to_name", true),
 	),
 	"table_create": newCommandFormat(
 		nil,
-		newParamFormat("name", nil, true),
-		newParamFormat("flags", formatParamFlags, false),
-		newParamFormat("key_type", nil, false),
-		newParamFormat("value_type", nil, false),
-		newParamFormat("default_tokenizer", nil, false),
-		newParamFormat("normalizer", nil, false),
-		newParamFormat("token_filters", formatParamCSV, false),
+		newTableParamFormat("name", true),
+		newParamFormat("flags", ParamFlags, false),
+		newParamFormat("key_type", ParamValue, false),
+		newParamFormat("value_type", ParamValue, false),
+		newParamFormat("default_tokenizer", ParamValue, false),
+		newParamFormat("normalizer", ParamValue, false),
+		newParamFormat("token_filters", ParamCSV, false),
+		newParamFormat("path", ParamValue, false),
 	),
 	"table_list": newCommandFormat(nil),
 	"table_remove": newCommandFormat(
 		nil,
-		newParamFormat("name", nil, true),
-		newParamFormat("dependent", formatParamYesNo, false),
+		newTableParamFormat("name", true),
+		newParamFormat("dependent", ParamYesNo, false),
 	),
 	"table_rename": newCommandFormat(
 		nil,
-		newParamFormat("name", nil, true),
-		newParamFormat("new_name", nil, true),
+		newTableParamFormat("name", true),
+		newTableParamFormat("new_name", true),
 	),
 	"table_tokenize": newCommandFormat(
 		nil,
-		newParamFormat("table", nil, true),
-		newParamFormat("string", nil, true),
-		newParamFormat("flags", formatParamFlags, false),
-		newParamFormat("mode", nil, false),
-		newParamFormat("index_column", nil, false),
+		newTableParamFormat("table", true),
+		newParamFormat("string", ParamValue, true),
+		newParamFormat("flags", ParamFlags, false),
+		newEnumParamFormat("mode", false, "ADD", "GET"),
+		newParamFormat("index_column", ParamValue, false),
 	),
+	"thread_dump": newCommandFormat(nil),
 	"thread_limit": newCommandFormat(
 		nil,
-		newParamFormat("max", nil, false),
+		newIntParamFormat("max", false),
 	),
 	"tokenize": newCommandFormat(
 		nil,
-		newParamFormat("tokenizer", nil, true),
-		newParamFormat("string", nil, true),
-		newParamFormat("normalizer", nil, false),
-		newParamFormat("flags", formatParamFlags, false),
-		newParamFormat("mode", nil, false),
-		newParamFormat("token_filters", formatParamCSV, false),
+		newParamFormat("tokenizer", ParamValue, true),
+		newParamFormat("string", ParamValue, true),
+		newParamFormat("normalizer", ParamValue, false),
+		newParamFormat("flags", ParamFlags, false),
+		newEnumParamFormat("mode", false, "ADD", "GET"),
+		newParamFormat("token_filters", ParamCSV, false),
 	),
 	"tokenizer_list": newCommandFormat(nil),
 	"truncate": newCommandFormat(
 		nil,
-		newParamFormat("target_name", nil, true),
+		newParamFormat("target_name", ParamValue, true),
 	),
 }
 
@@ -788,17 +961,53 @@ func (c *Command) NeedsBody() bool {
 	return false
 }
 
-// Check checks whether or not the command has required components.
+// Check checks whether or not the command has required components and
+// valid parameter values.
+// All the parameter failures are reported as one error whose "errors"
+// contains the details.
+// If only required parameters are missing, the message is
+// "The command requires the key.".
 func (c *Command) Check() error {
+	var errs []map[string]interface{}
 	for _, pf := range c.format.requiredParams {
 		if _, ok := c.params[pf.key]; !ok {
-			return NewError(CommandError, "The command requires the key.", map[string]interface{}{
-				"name":   c.name,
-				"params": c.params,
-				"key":    pf.key,
+			errs = append(errs, map[string]interface{}{
+				"key":   pf.key,
+				"error": "The command requires the key.",
+			})
+		}
+	}
+	nMissing := len(errs)
+	for _, pf := range c.format.params {
+		value, ok := c.params[pf.key]
+		if !ok {
+			continue
+		}
+		if err := pf.Check(value); err != nil {
+			msg := err.Error()
+			if e, ok := err.(*Error); ok {
+				msg = e.Message
+			}
+			errs = append(errs, map[string]interface{}{
+				"key":   pf.key,
+				"value": value,
+				"error": msg,
 			})
 		}
 	}
+	if len(errs) != 0 {
+		msg := "The command has invalid parameters."
+		if len(errs) == nMissing {
+			msg = "The command requires the key."
+		}
+		err := NewError(CommandError, msg, map[string]interface{}{
+			"name":   c.name,
+			"params": c.params,
+			"errors": errs,
+		})
+		err.Data["key"] = errs[0]["key"]
+		return err
+	}
 	if !c.NeedsBody() && c.body != nil {
 		return NewError(CommandError, "The command does not require a body", map[string]interface{}{
 			"name":   c.name,
@@ -893,6 +1102,90 @@ func (c *Command) String() string {
 	return string(cmd)
 }
 
+// URL assembles the command name and parameters in the HTTP form,
+// such as prefix/select.json?table=Tbl.
+// --output_type is converted into the suffix of the command name.
+// Note that the body is not included.
+func (c *Command) URL(prefix string) string {
+	buf := []byte(strings.TrimRight(prefix, "/"))
+	buf = append(buf, '/')
+	buf = append(buf, c.name...)
+	query := make(url.Values)
+	for k, v := range c.params {
+		if k == "output_type" {
+			continue
+		}
+		query.Set(k, v)
+	}
+	if outputType, ok := c.params["output_type"]; ok {
+		buf = append(buf, '.')
+		buf = append(buf, url.PathEscape(outputType)...)
+	}
+	if len(query) != 0 {
+		buf = append(buf, '?')
+		buf = append(buf, query.Encode()...)
+	}
+	return string(buf)
+}
+
+// ParseCommandURL parses a URL in the HTTP form and returns a new Command.
+// The last path element is the command name, and its suffix, if any,
+// is handled as --output_type.
+func ParseCommandURL(rawurl string) (*Command, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, NewError(CommandError, "url.Parse failed.", map[string]interface{}{
+			"url":   rawurl,
+			"error": err.Error(),
+		})
+	}
+	name := u.Path
+	if i := strings.LastIndexByte(name, '/'); i != -1 {
+		name = name[i+1:]
+	}
+	var outputType string
+	if i := strings.IndexByte(name, '.'); i != -1 {
+		outputType = name[i+1:]
+		name = name[:i]
+	}
+	c, err := newCommand(name)
+	if err != nil {
+		if e, ok := err.(*Error); ok {
+			e.Data["url"] = rawurl
+		}
+		return nil, err
+	}
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if vs := query[k]; len(vs) != 1 {
+			return nil, NewError(CommandError, "The key appears more than once.", map[string]interface{}{
+				"url": rawurl,
+				"key": k,
+			})
+		}
+		if err := c.SetParam(k, query.Get(k)); err != nil {
+			if e, ok := err.(*Error); ok {
+				e.Data["url"] = rawurl
+			}
+			return nil, err
+		}
+	}
+	if outputType != "" {
+		if err := c.SetParam("output_type", outputType); err != nil {
+			if e, ok := err.(*Error); ok {
+				e.Data["url"] = rawurl
+			}
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
 // commandBodyReader is a reader for command bodies.
 type commandBodyReader struct {
 	reader *CommandReader // Underlying reader
@@ -934,7 +1227,7 @@ func (br *commandBodyReader) checkLine() error {
 					i++
 				}
 			case top: // Close the quoted string.
-				br.stack = br.stack[len(br.stack)-1:]
+				br.stack = br.stack[:len(br.stack)-1]
 				top = br.stack[len(br.stack)-1]
 			}
 		default:
@@ -949,6 +1242,11 @@ func (br *commandBodyReader) checkLine() error {
 				if br.line[i] != top {
 					return io.EOF
 				}
+				br.stack = br.stack[:len(br.stack)-1]
+				if len(br.stack) == 0 { // The body ends with the current line.
+					return io.EOF
+				}
+				top = br.stack[len(br.stack)-1]
 			}
 		}
 	}
@@ -964,7 +1262,7 @@ func (br *commandBodyReader) Read(p []byte) (n int, err error) {
 	for n < len(p) {
 		if len(br.left) == 0 {
 			if err = br.checkLine(); err != nil {
-				cr.err = err
+				br.err = err
 				return
 			}
 			br.line, err = cr.readLine()
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestFormatParamValue(t *testing.T) {
@@ -87,6 +88,17 @@ func TestFormatParamValue(t *testing.T) {
 	} else if want := "String"; actual != want {
 		t.Fatalf("formatParamValue failed: actual = %s, want = %s", actual, want)
 	}
+
+	if actual, err := formatParamValue("", time.Unix(1483228800, 123456789)); err != nil {
+		t.Fatalf("formatParamValue failed: %v", err)
+	} else if want := "1483228800.123456"; actual != want {
+		t.Fatalf("formatParamValue failed: actual = %s, want = %s", actual, want)
+	}
+	if actual, err := formatParamValue("", time.Unix(1483228800, 0)); err != nil {
+		t.Fatalf("formatParamValue failed: %v", err)
+	} else if want := "1483228800"; actual != want {
+		t.Fatalf("formatParamValue failed: actual = %s, want = %s", actual, want)
+	}
 }
 
 func TestFormatParamYesNo(t *testing.T) {
@@ -334,6 +346,36 @@ func TestCommandString(t *testing.T) {
 	}
 }
 
+func TestCommandURL(t *testing.T) {
+	cmd, err := ParseCommand(`select Tbl --query 'a b&c' --output_type json --limit -1`)
+	if err != nil {
+		t.Fatalf("ParseCommand failed: %v", err)
+	}
+	actual := cmd.URL("http://localhost:10041/d/")
+	want := "http://localhost:10041/d/select.json?limit=-1&query=a+b%26c&table=Tbl"
+	if actual != want {
+		t.Fatalf("cmd.URL failed: actual = %s, want = %s", actual, want)
+	}
+	cmd, err = ParseCommandURL(actual)
+	if err != nil {
+		t.Fatalf("ParseCommandURL failed: %v", err)
+	}
+	if actual, want := cmd.String(), `select --limit '-1' --output_type 'json' --query 'a b&c' --table 'Tbl'`; actual != want {
+		t.Fatalf("ParseCommandURL failed: actual = %s, want = %s", actual, want)
+	}
+	if cmd, err = NewCommand("status", nil); err != nil {
+		t.Fatalf("NewCommand failed: %v", err)
+	}
+	if actual, want := cmd.URL("/d"), "/d/status"; actual != want {
+		t.Fatalf("cmd.URL failed: actual = %s, want = %s", actual, want)
+	}
+	for _, src := range []string{"/d/no_such_command", "/d/select?table=A&table=B", "/d/logical_select?min_border=sideways"} {
+		if _, err := ParseCommandURL(src); err == nil {
+			t.Fatalf("ParseCommandURL wrongly succeeded: src = %s", src)
+		}
+	}
+}
+
 func TestCommandNeedsBody(t *testing.T) {
 	data := map[string]bool{
 		"status":                       false,
@@ -353,6 +395,72 @@ func TestCommandNeedsBody(t *testing.T) {
 	}
 }
 
+func TestCommandCheck(t *testing.T) {
+	data := map[string]string{
+		"select Tbl --limit 10 --offset -1":             "",
+		"select Tbl --limit abc":                        "limit",
+		"select --limit 1":                              "table",
+		"shutdown --mode Graceful":                      "",
+		"shutdown --mode sideways":                      "mode",
+		"column_create Tbl col COLUMN_SCALAR Int32":     "",
+		"column_create Tbl _col COLUMN_SCALAR Int32":    "name",
+		"logical_range_filter Logs ts --order sideways": "order",
+		"select Logs#2017 --limit 1":                    "",
+		"select site@example-com":                       "",
+		"column_create Tbl#1 col-x COLUMN_SCALAR Int32": "",
+		"select Tbl.col":                                "table",
+		"select Int32":                                  "table",
+	}
+	for src, want := range data {
+		cmd, err := ParseCommand(src)
+		if err != nil {
+			t.Fatalf("ParseCommand failed: %v", err)
+		}
+		err = cmd.Check()
+		if want == "" {
+			if err != nil {
+				t.Fatalf("cmd.Check failed: src = %s, err = %v", src, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Fatalf("cmd.Check wrongly succeeded: src = %s", src)
+		}
+		if actual := err.(*Error).Data["key"]; actual != want {
+			t.Fatalf("cmd.Check failed: src = %s, actual = %v, want = %s", src, actual, want)
+		}
+	}
+	cmd, err := ParseCommand("select --limit x --offset y")
+	if err != nil {
+		t.Fatalf("ParseCommand failed: %v", err)
+	}
+	err = cmd.Check()
+	if err == nil {
+		t.Fatalf("cmd.Check wrongly succeeded")
+	}
+	e := err.(*Error)
+	if e.Code != CommandError {
+		t.Fatalf("cmd.Check failed: code = %d, want = %d", e.Code, CommandError)
+	}
+	if errs := e.Data["errors"].([]map[string]interface{}); len(errs) != 3 {
+		t.Fatalf("cmd.Check failed: errors = %v", errs)
+	}
+	if actual, want := e.Data["key"], "table"; actual != want {
+		t.Fatalf("cmd.Check failed: actual = %v, want = %s", actual, want)
+	}
+	if actual, want := e.Message, "The command has invalid parameters."; actual != want {
+		t.Fatalf("cmd.Check failed: actual = %s, want = %s", actual, want)
+	}
+	if cmd, err = ParseCommand("select --limit 1"); err != nil {
+		t.Fatalf("ParseCommand failed: %v", err)
+	}
+	if err := cmd.Check(); err == nil {
+		t.Fatalf("cmd.Check wrongly succeeded")
+	} else if actual, want := err.(*Error).Message, "The command requires the key."; actual != want {
+		t.Fatalf("cmd.Check failed: actual = %s, want = %s", actual, want)
+	}
+}
+
 func TestCommandReader(t *testing.T) {
 	dump := `table_create Tbl TABLE_NO_KEY
 column_create Tbl col COLUMN_SCALAR Text
@@ -367,6 +475,9 @@ load --table Tbl
 ["Hello, world!"],
 ["'{' is called a left brace."]
 ]
+load --table Tbl
+[["col"],["\"]\" is called a right bracket."]]
+table_list
 `
 	cr := NewCommandReader(strings.NewReader(dump))
 	if cmd, err := cr.Read(); err != nil {
@@ -403,9 +514,51 @@ load --table Tbl
 `; actual != want {
 		t.Fatalf("io.ReadAll failed: actual = %s, want = %s", actual, want)
 	}
+	if cmd, err := cr.Read(); err != nil {
+		t.Fatalf("cr.Read failed: %v", err)
+	} else if actual, want := cmd.Name(), "load"; actual != want {
+		t.Fatalf("cr.Read failed: actual = %s, want = %s", actual, want)
+	} else if body, err := ioutil.ReadAll(cmd.Body()); err != nil {
+		t.Fatalf("io.ReadAll failed: %v", err)
+	} else if actual, want := string(body), `[["col"],["\"]\" is called a right bracket."]]
+`; actual != want {
+		t.Fatalf("io.ReadAll failed: actual = %s, want = %s", actual, want)
+	}
+	if cmd, err := cr.Read(); err != nil {
+		t.Fatalf("cr.Read failed: %v", err)
+	} else if actual, want := cmd.Name(), "table_list"; actual != want {
+		t.Fatalf("cr.Read failed: actual = %s, want = %s", actual, want)
+	}
 	if _, err := cr.Read(); err == nil {
 		t.Fatalf("cr.Read wongly succeeded")
 	} else if err != io.EOF {
 		t.Fatalf("cr.Read  failed: %v", err)
 	}
 }
+
+func TestCommandReaderBodyEnd(t *testing.T) {
+	bodies := []string{
+		`[["col"],["a"]]`,
+		`[{"col":"]"},{"col":"[\"}"}]`,
+		"[\n[\"col\"],\n[\"a]\"]]",
+		`[]`,
+	}
+	for _, body := range bodies {
+		cr := NewCommandReader(strings.NewReader("load --table Tbl\n" + body + "\nstatus\n"))
+		if cmd, err := cr.Read(); err != nil {
+			t.Fatalf("cr.Read failed: %v", err)
+		} else if actual, err := ioutil.ReadAll(cmd.Body()); err != nil {
+			t.Fatalf("io.ReadAll failed: %v", err)
+		} else if want := body + "\n"; string(actual) != want {
+			t.Fatalf("io.ReadAll failed: actual = %s, want = %s", actual, want)
+		}
+		if cmd, err := cr.Read(); err != nil {
+			t.Fatalf("cr.Read failed: body = %s, err = %v", body, err)
+		} else if actual, want := cmd.Name(), "status"; actual != want {
+			t.Fatalf("cr.Read failed: actual = %s, want = %s", actual, want)
+		}
+		if _, err := cr.Read(); err != io.EOF {
+			t.Fatalf("cr.Read failed: err = %v", err)
+		}
+	}
+}
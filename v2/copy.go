@@ -0,0 +1,512 @@
+package grnci
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// CopyDatabaseProgress is a progress report of CopyDatabase.
+type CopyDatabaseProgress struct {
+	Table   string // Table name
+	NCopied int    // Number of records copied
+	NTotal  int    // Number of records in the source table
+	Done    bool   // Whether or not the table is completed
+	Err     error  // Error if the table is failed
+}
+
+// CopyDatabaseOptions stores options for CopyDatabase.
+type CopyDatabaseOptions struct {
+	Tables      []string                    // Tables to be copied (all tables if empty)
+	PageSize    int                         // Number of records per select and load
+	Parallelism int                         // Number of tables copied in parallel
+	Progress    func(*CopyDatabaseProgress) // Callback for progress reports
+}
+
+// NewCopyDatabaseOptions returns the default CopyDatabaseOptions.
+func NewCopyDatabaseOptions() *CopyDatabaseOptions {
+	return &CopyDatabaseOptions{
+		PageSize:    1000,
+		Parallelism: 4,
+	}
+}
+
+// copyDatabase stores the state of CopyDatabase.
+type copyDatabase struct {
+	src      *DB
+	dst      *DB
+	schema   *DBSchema
+	tables   map[string]bool // Tables to be copied
+	options  *CopyDatabaseOptions
+	mutex    sync.Mutex // Mutex for err and the progress callback
+	err      error      // First error
+	progress func(*CopyDatabaseProgress)
+}
+
+// CopyDatabase copies the schema and records from src to dst.
+//
+// CopyDatabase reads the schema of src and recreates plugins, tables and
+// data columns on dst in dependency order.
+// Then, it copies records table-by-table with paged selects and batched loads,
+// where tables are copied in parallel.
+// Index columns are created at last so that indexes are built at once.
+//
+// Record IDs of tables without keys are preserved even if
+// the source table has deleted records.
+func CopyDatabase(src, dst Handler, options *CopyDatabaseOptions) error {
+	if options == nil {
+		options = NewCopyDatabaseOptions()
+	}
+	if options.PageSize <= 0 {
+		return NewError(CommandError, "The page size must be positive.", map[string]interface{}{
+			"pageSize": options.PageSize,
+		})
+	}
+	c := &copyDatabase{
+		src:      NewDB(src),
+		dst:      NewDB(dst),
+		options:  options,
+		progress: options.Progress,
+	}
+	schema, err := c.src.Schema()
+	if err != nil {
+		return err
+	}
+	c.schema = schema
+	if err := c.selectTables(); err != nil {
+		return err
+	}
+	order, err := c.sortTables()
+	if err != nil {
+		return err
+	}
+	if err := c.createSchema(order); err != nil {
+		return err
+	}
+	// Tables without keys must be copied before tables referring to them
+	// because references to such tables are resolved by IDs.
+	var referred, others []string
+	for _, name := range order {
+		if c.isReferredArray(name) {
+			referred = append(referred, name)
+		} else {
+			others = append(others, name)
+		}
+	}
+	if err := c.copyRecords(referred); err != nil {
+		return err
+	}
+	if err := c.copyRecords(others); err != nil {
+		return err
+	}
+	return c.createIndexes(order)
+}
+
+// isReferredArray returns whether or not the table has no key and
+// is referred to by data columns of the target tables.
+func (c *copyDatabase) isReferredArray(name string) bool {
+	if c.schema.Tables[name].KeyType != nil {
+		return false
+	}
+	for tblName := range c.tables {
+		for _, col := range c.schema.Tables[tblName].Columns {
+			if col.Type != "index" && col.ValueType.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// selectTables selects the target tables and their dependencies.
+func (c *copyDatabase) selectTables() error {
+	c.tables = make(map[string]bool)
+	if len(c.options.Tables) == 0 {
+		for name := range c.schema.Tables {
+			c.tables[name] = true
+		}
+		return nil
+	}
+	var add func(name string) error
+	add = func(name string) error {
+		if c.tables[name] {
+			return nil
+		}
+		tbl, ok := c.schema.Tables[name]
+		if !ok {
+			return NewError(OperationError, "The table does not exist.", map[string]interface{}{
+				"name": name,
+			})
+		}
+		c.tables[name] = true
+		for _, dep := range c.tableDeps(&tbl) {
+			if err := add(dep); err != nil {
+				return err
+			}
+		}
+		for _, col := range tbl.Columns {
+			if _, ok := c.schema.Tables[col.ValueType.Name]; ok {
+				if err := add(col.ValueType.Name); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	for _, name := range c.options.Tables {
+		if err := add(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableDeps returns the names of tables that tbl refers to as key or value type.
+func (c *copyDatabase) tableDeps(tbl *DBSchemaTable) []string {
+	var deps []string
+	if tbl.KeyType != nil {
+		if _, ok := c.schema.Tables[tbl.KeyType.Name]; ok && tbl.KeyType.Name != tbl.Name {
+			deps = append(deps, tbl.KeyType.Name)
+		}
+	}
+	if tbl.ValueType != nil {
+		if _, ok := c.schema.Tables[tbl.ValueType.Name]; ok && tbl.ValueType.Name != tbl.Name {
+			deps = append(deps, tbl.ValueType.Name)
+		}
+	}
+	return deps
+}
+
+// sortTables returns the target tables in dependency order.
+func (c *copyDatabase) sortTables() ([]string, error) {
+	names := make([]string, 0, len(c.tables))
+	for name := range c.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	visited := make(map[string]bool)
+	visiting := make(map[string]bool)
+	var order []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		if visited[name] {
+			return nil
+		}
+		if visiting[name] {
+			return NewError(OperationError, "The tables have a circular dependency.", map[string]interface{}{
+				"name": name,
+			})
+		}
+		visiting[name] = true
+		tbl := c.schema.Tables[name]
+		for _, dep := range c.tableDeps(&tbl) {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		visited[name] = true
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// execSchemaCommand executes a command stored in the schema.
+func (c *copyDatabase) execSchemaCommand(cmd *DBSchemaCommand) error {
+	params := make(map[string]interface{}, len(cmd.Arguments))
+	for k, v := range cmd.Arguments {
+		params[k] = v
+	}
+	resp, err := c.dst.Invoke(cmd.Name, params, nil)
+	if err != nil {
+		return err
+	}
+	if err := c.dst.recvBool(resp); err != nil {
+		if e, ok := err.(*Error); ok {
+			e.Data["command"] = cmd.CommandLine
+		}
+		return err
+	}
+	return nil
+}
+
+// sortedColumns returns the columns of tbl sorted by name.
+func sortedColumns(tbl *DBSchemaTable) []DBSchemaColumn {
+	names := make([]string, 0, len(tbl.Columns))
+	for name := range tbl.Columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	cols := make([]DBSchemaColumn, len(names))
+	for i, name := range names {
+		cols[i] = tbl.Columns[name]
+	}
+	return cols
+}
+
+// createSchema registers plugins and creates tables and data columns.
+func (c *copyDatabase) createSchema(order []string) error {
+	plugins := make([]string, 0, len(c.schema.Plugins))
+	for name := range c.schema.Plugins {
+		plugins = append(plugins, name)
+	}
+	sort.Strings(plugins)
+	for _, name := range plugins {
+		if err := c.dst.PluginRegister(name); err != nil {
+			return err
+		}
+	}
+	for _, name := range order {
+		tbl := c.schema.Tables[name]
+		if err := c.execSchemaCommand(&tbl.Command); err != nil {
+			return err
+		}
+	}
+	for _, name := range order {
+		tbl := c.schema.Tables[name]
+		for _, col := range sortedColumns(&tbl) {
+			if col.Type == "index" {
+				continue
+			}
+			if err := c.execSchemaCommand(&col.Command); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// createIndexes creates index columns.
+func (c *copyDatabase) createIndexes(order []string) error {
+	for _, name := range order {
+		tbl := c.schema.Tables[name]
+		for _, col := range sortedColumns(&tbl) {
+			if col.Type != "index" || !c.tables[col.ValueType.Name] {
+				continue
+			}
+			if err := c.execSchemaCommand(&col.Command); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// report calls the progress callback and stores the first error.
+func (c *copyDatabase) report(p *CopyDatabaseProgress) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if p.Err != nil && c.err == nil {
+		c.err = p.Err
+	}
+	if c.progress != nil {
+		c.progress(p)
+	}
+}
+
+// failed returns whether or not an error has occurred.
+func (c *copyDatabase) failed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.err != nil
+}
+
+// copyRecords copies records of the target tables in parallel.
+func (c *copyDatabase) copyRecords(order []string) error {
+	n := c.options.Parallelism
+	if n <= 0 {
+		n = 1
+	}
+	names := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range names {
+				if c.failed() {
+					continue
+				}
+				c.copyTable(name)
+			}
+		}()
+	}
+	for _, name := range order {
+		names <- name
+	}
+	close(names)
+	wg.Wait()
+	return c.err
+}
+
+// copyTable copies records of a table.
+//
+// Records of a table without key are loaded with _id and
+// deleted records are recreated as placeholders, which are deleted at last,
+// so that references to the table are preserved.
+func (c *copyDatabase) copyTable(name string) {
+	tbl := c.schema.Tables[name]
+	keyless := tbl.KeyType == nil
+	cols := []string{"_id"}
+	if !keyless {
+		cols = append(cols, "_key")
+	}
+	if tbl.ValueType != nil {
+		cols = append(cols, "_value")
+	}
+	for _, col := range sortedColumns(&tbl) {
+		if col.Type != "index" {
+			cols = append(cols, col.Name)
+		}
+	}
+	loadCols := cols[1:]
+	if keyless {
+		loadCols = cols
+	}
+	progress := &CopyDatabaseProgress{Table: name}
+	fail := func(err error) {
+		progress.Err = err
+		c.report(progress)
+	}
+	options := NewDBSelectOptions()
+	options.OutputColumns = cols
+	options.SortKeys = []string{"_id"}
+	options.Limit = c.options.PageSize
+	var lastID uint64
+	var holes []uint64 // IDs of placeholders
+	for {
+		options.Filter = "_id > " + strconv.FormatUint(lastID, 10)
+		nHits, ids, rows, err := c.selectPage(name, options, keyless)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if progress.NTotal == 0 {
+			progress.NTotal = nHits
+		}
+		if len(ids) == 0 {
+			break
+		}
+		begin := 0
+		for i, id := range ids {
+			if !keyless || id == lastID+1 {
+				lastID = id
+				continue
+			}
+			// Load the preceding records and placeholders for deleted records.
+			n, err := c.load(name, loadCols, rows[begin:i])
+			progress.NCopied += n
+			if err != nil {
+				fail(err)
+				return
+			}
+			var placeholders [][]byte
+			for hole := lastID + 1; hole < id; hole++ {
+				placeholders = append(placeholders, []byte("["+strconv.FormatUint(hole, 10)+"]"))
+				holes = append(holes, hole)
+			}
+			if _, err := c.load(name, []string{"_id"}, placeholders); err != nil {
+				fail(err)
+				return
+			}
+			begin = i
+			lastID = id
+		}
+		n, err := c.load(name, loadCols, rows[begin:])
+		progress.NCopied += n
+		if err != nil {
+			fail(err)
+			return
+		}
+		if len(ids) < c.options.PageSize || c.failed() {
+			break
+		}
+		c.report(progress)
+	}
+	for _, hole := range holes {
+		if err := c.dst.DeleteByID(name, int(hole)); err != nil {
+			fail(err)
+			return
+		}
+	}
+	progress.Done = true
+	c.report(progress)
+}
+
+// load loads rows into a table and returns the number of loaded records.
+func (c *copyDatabase) load(name string, cols []string, rows [][]byte) (int, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	body := []byte("[")
+	body = append(body, bytes.Join(rows, []byte(","))...)
+	body = append(body, ']')
+	options := NewDBLoadOptions()
+	options.Columns = cols
+	return c.dst.Load(name, bytes.NewReader(body), options)
+}
+
+// selectPage selects a page and returns the number of hits,
+// IDs of the records and the records as JSON arrays.
+// The records include IDs if withID is true.
+func (c *copyDatabase) selectPage(name string, options *DBSelectOptions, withID bool) (int, []uint64, [][]byte, error) {
+	result, err := c.src.Select(name, options)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer result.Close()
+	data, err := ioutil.ReadAll(result)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	var raw [][][]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return 0, nil, nil, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	if len(raw) == 0 || len(raw[0]) < 2 || len(raw[0][0]) == 0 {
+		return 0, nil, nil, NewError(ResponseError, "The response format is invalid.", map[string]interface{}{
+			"command": "select",
+		})
+	}
+	var nHits int
+	if err := json.Unmarshal(raw[0][0][0], &nHits); err != nil {
+		return 0, nil, nil, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	rawRecs := raw[0][2:]
+	ids := make([]uint64, len(rawRecs))
+	rows := make([][]byte, len(rawRecs))
+	for i, rawRec := range rawRecs {
+		if len(rawRec) == 0 {
+			return 0, nil, nil, NewError(ResponseError, "The record is empty.", nil)
+		}
+		if err := json.Unmarshal(rawRec[0], &ids[i]); err != nil {
+			return 0, nil, nil, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		if !withID {
+			rawRec = rawRec[1:]
+		}
+		row := []byte("[")
+		for j, v := range rawRec {
+			if j != 0 {
+				row = append(row, ',')
+			}
+			row = append(row, v...)
+		}
+		rows[i] = append(row, ']')
+	}
+	return nHits, ids, rows, nil
+}
@@ -0,0 +1,164 @@
+package grnci
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCopyDatabase(t *testing.T) {
+	schema := `{
+  "plugins": {},
+  "types": {},
+  "tables": {
+    "Posts": {
+      "name": "Posts",
+      "type": "array",
+      "key_type": null,
+      "value_type": null,
+      "command": {
+        "name": "table_create",
+        "arguments": {"name": "Posts", "flags": "TABLE_NO_KEY"},
+        "command_line": "table_create --name Posts --flags TABLE_NO_KEY"
+      },
+      "columns": {
+        "mark": {
+          "name": "mark",
+          "table": "Posts",
+          "type": "scalar",
+          "value_type": {"name": "Marks", "type": "reference"},
+          "command": {
+            "name": "column_create",
+            "arguments": {"table": "Posts", "name": "mark", "flags": "COLUMN_SCALAR", "type": "Marks"},
+            "command_line": "column_create --table Posts --name mark --flags COLUMN_SCALAR --type Marks"
+          }
+        },
+        "title": {
+          "name": "title",
+          "table": "Posts",
+          "type": "scalar",
+          "value_type": {"name": "ShortText", "type": "type"},
+          "command": {
+            "name": "column_create",
+            "arguments": {"table": "Posts", "name": "title", "flags": "COLUMN_SCALAR", "type": "ShortText"},
+            "command_line": "column_create --table Posts --name title --flags COLUMN_SCALAR --type ShortText"
+          }
+        }
+      }
+    },
+    "Marks": {
+      "name": "Marks",
+      "type": "array",
+      "key_type": null,
+      "value_type": null,
+      "command": {
+        "name": "table_create",
+        "arguments": {"name": "Marks", "flags": "TABLE_NO_KEY"},
+        "command_line": "table_create --name Marks --flags TABLE_NO_KEY"
+      },
+      "columns": {}
+    },
+    "Terms": {
+      "name": "Terms",
+      "type": "patricia trie",
+      "key_type": {"name": "ShortText", "type": "type"},
+      "value_type": null,
+      "command": {
+        "name": "table_create",
+        "arguments": {"name": "Terms", "flags": "TABLE_PAT_KEY", "key_type": "ShortText", "default_tokenizer": "TokenBigram"},
+        "command_line": "table_create --name Terms --flags TABLE_PAT_KEY --key_type ShortText --default_tokenizer TokenBigram"
+      },
+      "columns": {
+        "posts_title": {
+          "name": "posts_title",
+          "table": "Terms",
+          "type": "index",
+          "value_type": {"name": "Posts", "type": "reference"},
+          "command": {
+            "name": "column_create",
+            "arguments": {"table": "Terms", "name": "posts_title", "flags": "COLUMN_INDEX|WITH_POSITION", "type": "Posts", "source": "title"},
+            "command_line": "column_create --table Terms --name posts_title --flags COLUMN_INDEX|WITH_POSITION --type Posts --source title"
+          }
+        }
+      }
+    }
+  }
+}`
+	src := newTestHandler(func(cmd *Command, body string) (string, error) {
+		switch cmd.Name() {
+		case "schema":
+			return schema, nil
+		case "select":
+			switch cmd.Params()["table"] {
+			case "Marks":
+				return `[[[1],[["_id","UInt32"]],[2]]]`, nil
+			case "Posts":
+				switch cmd.Params()["filter"] {
+				case "_id > 0":
+					return `[[[3],[["_id","UInt32"],["mark","Marks"],["title","ShortText"]],[1,2,"a"],[3,0,"b"]]]`, nil
+				case "_id > 3":
+					return `[[[1],[["_id","UInt32"],["mark","Marks"],["title","ShortText"]],[4,2,"c"]]]`, nil
+				}
+			case "Terms":
+				return `[[[0],[["_id","UInt32"],["_key","ShortText"]]]]`, nil
+			}
+		}
+		return "", NewError(GroongaError, "Unexpected command.", map[string]interface{}{
+			"command": cmd.String(),
+		})
+	})
+	dst := newTestHandler(func(cmd *Command, body string) (string, error) {
+		if cmd.Name() == "load" {
+			return "1", nil
+		}
+		return "true", nil
+	})
+	options := NewCopyDatabaseOptions()
+	options.PageSize = 2
+	var mutex sync.Mutex
+	copied := make(map[string]int)
+	options.Progress = func(p *CopyDatabaseProgress) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if p.Err != nil {
+			t.Errorf("CopyDatabase failed: table = %s, err = %v", p.Table, p.Err)
+		}
+		if p.Done {
+			copied[p.Table] = p.NCopied
+		}
+	}
+	if err := CopyDatabase(src, dst, options); err != nil {
+		t.Fatalf("CopyDatabase failed: %v", err)
+	}
+	if copied["Posts"] != 3 || copied["Marks"] != 1 || copied["Terms"] != 0 {
+		t.Fatalf("CopyDatabase failed: copied = %v", copied)
+	}
+
+	var cmds []string
+	for i, cmd := range dst.commands {
+		s := cmd.String()
+		if cmd.Name() == "load" {
+			s += " " + dst.bodies[i]
+		}
+		cmds = append(cmds, s)
+	}
+	want := []string{
+		"table_create --flags 'TABLE_NO_KEY' --name 'Marks'",
+		"table_create --flags 'TABLE_NO_KEY' --name 'Posts'",
+		"table_create --default_tokenizer 'TokenBigram' --flags 'TABLE_PAT_KEY' --key_type 'ShortText' --name 'Terms'",
+		"column_create --flags 'COLUMN_SCALAR' --name 'mark' --table 'Posts' --type 'Marks'",
+		"column_create --flags 'COLUMN_SCALAR' --name 'title' --table 'Posts' --type 'ShortText'",
+		"load --columns '_id' --table 'Marks' [[1]]",
+		"load --columns '_id' --table 'Marks' [[2]]",
+		"delete --id '1' --table 'Marks'",
+		"load --columns '_id,mark,title' --table 'Posts' [[1,2,\"a\"]]",
+		"load --columns '_id' --table 'Posts' [[2]]",
+		"load --columns '_id,mark,title' --table 'Posts' [[3,0,\"b\"]]",
+		"load --columns '_id,mark,title' --table 'Posts' [[4,2,\"c\"]]",
+		"delete --id '2' --table 'Posts'",
+		"column_create --flags 'COLUMN_INDEX|WITH_POSITION' --name 'posts_title' --source 'title' --table 'Terms' --type 'Posts'",
+	}
+	if actual := strings.Join(cmds, "\n"); actual != strings.Join(want, "\n") {
+		t.Fatalf("CopyDatabase failed: actual = %s, want = %s", actual, strings.Join(want, "\n"))
+	}
+}
@@ -6,14 +6,20 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // DB is a wrapper to provide a high-level command interface.
 type DB struct {
 	Handler
+	compat    bool            // Whether or not the compatibility mode is enabled
+	caps      *DBCapabilities // Cached capabilities
+	capsMutex sync.Mutex
 }
 
 // NewDB returns a new DB that wraps the specified client or handle.
@@ -128,6 +134,28 @@ func (db *DB) ColumnCopy(from, to string) error {
 
 // ColumnCreate executes column_create.
 func (db *DB) ColumnCreate(name, typ string, flags []string) error {
+	options := NewDBColumnCreateOptions()
+	options.Flags = flags
+	return db.ColumnCreateWithOptions(name, typ, options)
+}
+
+// DBColumnCreateOptions stores options for DB.ColumnCreateWithOptions.
+type DBColumnCreateOptions struct {
+	Flags []string // --flags (COLUMN_* is added by typ)
+	Path  string   // --path
+}
+
+// NewDBColumnCreateOptions returns the default DBColumnCreateOptions.
+func NewDBColumnCreateOptions() *DBColumnCreateOptions {
+	return &DBColumnCreateOptions{}
+}
+
+// ColumnCreateWithOptions executes column_create with options.
+func (db *DB) ColumnCreateWithOptions(name, typ string, options *DBColumnCreateOptions) error {
+	if options == nil {
+		options = NewDBColumnCreateOptions()
+	}
+	flags := append([]string(nil), options.Flags...)
 	i := strings.IndexByte(name, '.')
 	if i == -1 {
 		return NewError(CommandError, "The name must contain a dot.", map[string]interface{}{
@@ -157,6 +185,9 @@ func (db *DB) ColumnCreate(name, typ string, flags []string) error {
 	if srcs != nil {
 		params["source"] = srcs
 	}
+	if options.Path != "" {
+		params["path"] = options.Path
+	}
 	resp, err := db.Invoke("column_create", params, nil)
 	if err != nil {
 		return err
@@ -429,6 +460,58 @@ func (db *DB) Dump(options *DBDumpOptions) (io.ReadCloser, error) {
 	return resp, nil
 }
 
+// DBIndexColumnDiffToken is a token of DBIndexColumnDiff.
+type DBIndexColumnDiffToken struct {
+	ID    uint32      `json:"id"`
+	Value interface{} `json:"value"`
+}
+
+// DBIndexColumnDiffPosting is a posting of DBIndexColumnDiff.
+type DBIndexColumnDiffPosting struct {
+	RecordID  uint32 `json:"record_id"`
+	SectionID uint32 `json:"section_id"`
+	Position  uint32 `json:"position"`
+}
+
+// DBIndexColumnDiff is a result of index_column_diff.
+type DBIndexColumnDiff struct {
+	Token    DBIndexColumnDiffToken     `json:"token"`
+	Remains  []DBIndexColumnDiffPosting `json:"remains"`
+	Missings []DBIndexColumnDiffPosting `json:"missings"`
+}
+
+// IndexColumnDiff executes index_column_diff.
+func (db *DB) IndexColumnDiff(name string) ([]DBIndexColumnDiff, error) {
+	i := strings.IndexByte(name, '.')
+	if i == -1 {
+		return nil, NewError(CommandError, "The name must contain a dot.", map[string]interface{}{
+			"name": name,
+		})
+	}
+	resp, err := db.Invoke("index_column_diff", map[string]interface{}{
+		"table": name[:i],
+		"name":  name[i+1:],
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
+	jsonData, err := ioutil.ReadAll(resp)
+	if err != nil {
+		return nil, err
+	}
+	var result []DBIndexColumnDiff
+	if err := json.Unmarshal(jsonData, &result); err != nil {
+		return nil, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	return result, nil
+}
+
 // DBIOFlushOptions stores options for DB.IOFlush.
 type DBIOFlushOptions struct {
 	TargetName string // --target_name
@@ -465,8 +548,10 @@ func (db *DB) IOFlush(options *DBIOFlushOptions) error {
 // DBLoadOptions stores options for DB.Load.
 // http://groonga.org/docs/reference/commands/load.html
 type DBLoadOptions struct {
-	Columns  []string // --columns
-	IfExists string   // --ifexists
+	Columns   []string // --columns
+	IfExists  string   // --ifexists
+	Each      string   // --each
+	LockTable bool     // --lock_table
 }
 
 // NewDBLoadOptions returns the default DBLoadOptions.
@@ -474,8 +559,8 @@ func NewDBLoadOptions() *DBLoadOptions {
 	return &DBLoadOptions{}
 }
 
-// Load executes load.
-func (db *DB) Load(tbl string, values io.Reader, options *DBLoadOptions) (int, error) {
+// loadParams returns parameters of load.
+func (db *DB) loadParams(tbl string, options *DBLoadOptions) map[string]interface{} {
 	params := map[string]interface{}{
 		"table": tbl,
 	}
@@ -488,7 +573,18 @@ func (db *DB) Load(tbl string, values io.Reader, options *DBLoadOptions) (int, e
 	if options.IfExists != "" {
 		params["ifexists"] = options.IfExists
 	}
-	resp, err := db.Invoke("load", params, values)
+	if options.Each != "" {
+		params["each"] = options.Each
+	}
+	if options.LockTable {
+		params["lock_table"] = options.LockTable
+	}
+	return params
+}
+
+// Load executes load.
+func (db *DB) Load(tbl string, values io.Reader, options *DBLoadOptions) (int, error) {
+	resp, err := db.Invoke("load", db.loadParams(tbl, options), values)
 	if err != nil {
 		return 0, err
 	}
@@ -514,6 +610,72 @@ func (db *DB) Load(tbl string, values io.Reader, options *DBLoadOptions) (int, e
 	return result, resp.Err()
 }
 
+// DBLoadResult is a result of load with --output_ids.
+type DBLoadResult struct {
+	NLoaded   int      `json:"n_loaded_records"`
+	LoadedIDs []uint32 `json:"loaded_ids"` // 0 for records not loaded
+}
+
+// LoadIDs executes load with --output_ids and returns the loaded IDs.
+// LoadIDs requires command version 3.
+func (db *DB) LoadIDs(tbl string, values io.Reader, options *DBLoadOptions) (*DBLoadResult, error) {
+	params := db.loadParams(tbl, options)
+	params["output_ids"] = true
+	params["command_version"] = 3
+	resp, err := db.Invoke("load", params, values)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+	jsonData, err := ioutil.ReadAll(resp)
+	if err != nil {
+		if resp.Err() != nil {
+			return nil, resp.Err()
+		}
+		return nil, err
+	}
+	jsonData, err = unwrapResponseV3(jsonData)
+	if err != nil {
+		return nil, err
+	}
+	var result DBLoadResult
+	if err := json.Unmarshal(jsonData, &result); err != nil {
+		if resp.Err() != nil {
+			return nil, resp.Err()
+		}
+		return nil, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	return &result, resp.Err()
+}
+
+// unwrapResponseV3 returns the body of a command version 3 response.
+// HTTP responses are wrapped in {"header": ..., "body": ...},
+// while GQTP responses are not.
+func unwrapResponseV3(data []byte) ([]byte, error) {
+	var envelope struct {
+		Header *struct {
+			ReturnCode int `json:"return_code"`
+			Error      *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		} `json:"header"`
+		Body json.RawMessage `json:"body"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil || envelope.Header == nil {
+		return data, nil
+	}
+	if rc := envelope.Header.ReturnCode; rc != 0 {
+		err := NewError(ErrorCode(rc), "Error response received.", nil)
+		if envelope.Header.Error != nil {
+			err.Data["message"] = envelope.Header.Error.Message
+		}
+		return nil, err
+	}
+	return envelope.Body, nil
+}
+
 // appendRow appends the JSON-encoded row to buf nad returns the exetended buffer.
 func (db *DB) appendRow(body []byte, row reflect.Value, cfs []*ColumnField) []byte {
 	body = append(body, '[')
@@ -751,10 +913,296 @@ func (db *DB) LogicalParameters(rangeIndex string) (*DBLogicalParameters, error)
 	return &result, nil
 }
 
+// DBLogicalRangeFilterOptions stores options for DB.LogicalRangeFilter.
+// http://groonga.org/docs/reference/commands/logical_range_filter.html
+type DBLogicalRangeFilterOptions struct {
+	Min           time.Time // --min
+	MinBorder     bool      // --min_border
+	Max           time.Time // --max
+	MaxBorder     bool      // --max_border
+	Order         string    // --order
+	Filter        string    // --filter
+	Offset        int       // --offset
+	Limit         int       // --limit
+	OutputColumns []string  // --output_columns
+	UseRangeIndex string    // --use_range_index
+	Cache         bool      // --cache
+}
+
+// NewDBLogicalRangeFilterOptions returns the default DBLogicalRangeFilterOptions.
+func NewDBLogicalRangeFilterOptions() *DBLogicalRangeFilterOptions {
+	return &DBLogicalRangeFilterOptions{
+		MinBorder: true,
+		MaxBorder: true,
+		Limit:     10,
+		Cache:     true,
+	}
+}
+
 // LogicalRangeFilter executes logical_range_filter.
-func (db *DB) LogicalRangeFilter() error {
-	// TODO
-	return NewError(OperationError, "Not implemented yet.", nil)
+// On success, it is the caller's responsibility to close the result.
+func (db *DB) LogicalRangeFilter(logicalTable, shardKey string, options *DBLogicalRangeFilterOptions) (io.ReadCloser, error) {
+	if options == nil {
+		options = NewDBLogicalRangeFilterOptions()
+	}
+	params := map[string]interface{}{
+		"logical_table": logicalTable,
+		"shard_key":     shardKey,
+	}
+	if !options.Min.IsZero() {
+		params["min"] = options.Min
+	}
+	params["min_border"] = options.MinBorder
+	if !options.Max.IsZero() {
+		params["max"] = options.Max
+	}
+	params["max_border"] = options.MaxBorder
+	if options.Order != "" {
+		params["order"] = options.Order
+	}
+	if options.Filter != "" {
+		params["filter"] = options.Filter
+	}
+	if options.Offset != 0 {
+		params["offset"] = options.Offset
+	}
+	if options.Limit != 10 {
+		params["limit"] = options.Limit
+	}
+	if options.OutputColumns != nil {
+		params["output_columns"] = options.OutputColumns
+	}
+	if options.UseRangeIndex != "" {
+		params["use_range_index"] = options.UseRangeIndex
+	}
+	if !options.Cache {
+		params["cache"] = options.Cache
+	}
+	resp, err := db.Invoke("logical_range_filter", params, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Err(); err != nil {
+		resp.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// parseRangeFilterSet parses a result of logical_range_filter and returns
+// a record set in the same form as select.
+// logical_range_filter does not count hits, so n_hits is the number of records.
+func (db *DB) parseRangeFilterSet(data []byte) ([][]json.RawMessage, error) {
+	var raw [][][]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	if len(raw) == 0 || len(raw[0]) == 0 {
+		return nil, NewError(ResponseError, "The response is empty.", nil)
+	}
+	set := raw[0]
+	if len(set[0]) == 1 && len(set) >= 2 && isJSONNumber(set[0][0]) {
+		// [[N_HITS], COLUMNS, RECORDS...]
+		return set, nil
+	}
+	// [COLUMNS, RECORDS...]
+	nHits := []json.RawMessage{json.RawMessage(strconv.Itoa(len(set) - 1))}
+	return append([][]json.RawMessage{nHits}, set...), nil
+}
+
+// isJSONNumber returns whether or not data is a JSON number.
+func isJSONNumber(data json.RawMessage) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) != 0 && (data[0] == '-' || (data[0] >= '0' && data[0] <= '9'))
+}
+
+// LogicalRangeFilterRows executes logical_range_filter and decodes the result into rows.
+// It returns the number of records because logical_range_filter does not count hits.
+func (db *DB) LogicalRangeFilterRows(logicalTable, shardKey string, rows interface{}, options *DBLogicalRangeFilterOptions) (int, error) {
+	if options == nil {
+		options = NewDBLogicalRangeFilterOptions()
+	}
+	rs, err := GetRowStruct(rows)
+	if err != nil {
+		return 0, err
+	}
+	var cfs []*ColumnField
+	if options.OutputColumns == nil {
+		cfs = rs.Columns
+		for _, cf := range cfs {
+			options.OutputColumns = append(options.OutputColumns, cf.Name)
+		}
+	} else {
+		for _, col := range options.OutputColumns {
+			cf, ok := rs.ColumnsByName[col]
+			if !ok {
+				return 0, NewError(CommandError, "The column has no associated field.", map[string]interface{}{
+					"column": col,
+				})
+			}
+			cfs = append(cfs, cf)
+		}
+	}
+	result, err := db.LogicalRangeFilter(logicalTable, shardKey, options)
+	if err != nil {
+		return 0, err
+	}
+	defer result.Close()
+	data, err := ioutil.ReadAll(result)
+	if err != nil {
+		return 0, err
+	}
+	set, err := db.parseRangeFilterSet(data)
+	if err != nil {
+		return 0, err
+	}
+	return db.decodeRows(rows, set, cfs)
+}
+
+// DBRecordReader reads records one by one from a response.
+type DBRecordReader struct {
+	db      *DB
+	resp    io.ReadCloser
+	dec     *json.Decoder
+	Columns []DBRecordColumn // Output columns
+	types   []reflect.Type
+	rawCols []json.RawMessage
+}
+
+// newRecordReader returns a new DBRecordReader.
+// It reads the response until the first record.
+func (db *DB) newRecordReader(resp io.ReadCloser) (*DBRecordReader, error) {
+	r := &DBRecordReader{
+		db:   db,
+		resp: resp,
+		dec:  json.NewDecoder(resp),
+	}
+	for i := 0; i < 2; i++ {
+		if tok, err := r.dec.Token(); err != nil || tok != json.Delim('[') {
+			return nil, NewError(ResponseError, "The response is not a record set.", map[string]interface{}{
+				"token": tok,
+			})
+		}
+	}
+	var first []json.RawMessage
+	if err := r.dec.Decode(&first); err != nil {
+		return nil, NewError(ResponseError, "json.Decoder.Decode failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	r.rawCols = first
+	if len(first) == 1 && isJSONNumber(first[0]) {
+		// Skip [N_HITS].
+		r.rawCols = nil
+		if err := r.dec.Decode(&r.rawCols); err != nil {
+			return nil, NewError(ResponseError, "json.Decoder.Decode failed.", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}
+	records, err := db.parseRecords([][]json.RawMessage{{json.RawMessage("0")}, r.rawCols})
+	if err != nil {
+		return nil, err
+	}
+	r.Columns = records.Columns
+	if r.types, err = db.recordTypes(r.Columns); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// nextRaw reads the next raw record.
+func (r *DBRecordReader) nextRaw() ([]json.RawMessage, error) {
+	if !r.dec.More() {
+		return nil, io.EOF
+	}
+	var rec []json.RawMessage
+	if err := r.dec.Decode(&rec); err != nil {
+		return nil, NewError(ResponseError, "json.Decoder.Decode failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	if len(rec) != len(r.Columns) {
+		return nil, NewError(ResponseError, "nValues and nColumns must be same.", map[string]interface{}{
+			"nValues": len(rec),
+			"nCols":   len(r.Columns),
+		})
+	}
+	return rec, nil
+}
+
+// Next reads the next record.
+// If there are no more records, it returns io.EOF.
+func (r *DBRecordReader) Next() (DBRecord, error) {
+	raw, err := r.nextRaw()
+	if err != nil {
+		return nil, err
+	}
+	rec := make(DBRecord, len(raw))
+	for i, col := range r.Columns {
+		v, err := r.db.decodeRecord(raw[i], r.types[i])
+		if err != nil {
+			return nil, err
+		}
+		rec[col.Name] = v
+	}
+	return rec, nil
+}
+
+// NextRow reads the next record into row, a pointer to a struct.
+// If there are no more records, it returns io.EOF.
+func (r *DBRecordReader) NextRow(row interface{}) error {
+	if row == nil {
+		return NewError(CommandError, "The row must not be nil.", nil)
+	}
+	v := reflect.ValueOf(row)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return NewError(CommandError, "The row must be a non-nil pointer to a struct.", map[string]interface{}{
+			"type": reflect.TypeOf(row).String(),
+		})
+	}
+	rs, err := GetRowStruct(row)
+	if err != nil {
+		return err
+	}
+	raw, err := r.nextRaw()
+	if err != nil {
+		return err
+	}
+	cfs := make([]*ColumnField, len(r.Columns))
+	for i, col := range r.Columns {
+		cfs[i] = rs.ColumnsByName[col.Name]
+	}
+	rows := reflect.New(reflect.SliceOf(v.Elem().Type()))
+	set := [][]json.RawMessage{{json.RawMessage("1")}, r.rawCols, raw}
+	if _, err := r.db.decodeRows(rows.Interface(), set, cfs); err != nil {
+		return err
+	}
+	v.Elem().Set(rows.Elem().Index(0))
+	return nil
+}
+
+// Close closes the underlying response.
+func (r *DBRecordReader) Close() error {
+	return r.resp.Close()
+}
+
+// LogicalRangeFilterStream executes logical_range_filter and
+// returns a reader to read records one by one without buffering the response.
+// On success, it is the caller's responsibility to close the reader.
+func (db *DB) LogicalRangeFilterStream(logicalTable, shardKey string, options *DBLogicalRangeFilterOptions) (*DBRecordReader, error) {
+	resp, err := db.LogicalRangeFilter(logicalTable, shardKey, options)
+	if err != nil {
+		return nil, err
+	}
+	r, err := db.newRecordReader(resp)
+	if err != nil {
+		resp.Close()
+		return nil, err
+	}
+	return r, nil
 }
 
 // DBLogicalSelectOptions stores options for DB.LogicalSelect.
@@ -850,10 +1298,10 @@ func (db *DB) LogicalSelect(logicalTable, shardKey string, options *DBLogicalSel
 		params["drilldown_filter"] = options.DrilldownFilter
 	}
 	for name, col := range options.Columns {
-		col.setParams("--columns["+name+"]", params)
+		col.setParams("columns["+name+"]", params)
 	}
 	for label, drilldown := range options.Drilldowns {
-		drilldown.setParams("--drilldowns["+label+"]", params)
+		drilldown.setParams("drilldowns["+label+"]", params)
 	}
 	resp, err := db.Invoke("logical_select", params, nil)
 	if err != nil {
@@ -1297,6 +1745,32 @@ func (db *DB) ObjectRemove(name string, force bool) error {
 	return db.recvBool(resp)
 }
 
+// ObjectSetVisibility executes object_set_visibility.
+func (db *DB) ObjectSetVisibility(name string, visible bool) error {
+	resp, err := db.Invoke("object_set_visibility", map[string]interface{}{
+		"name":    name,
+		"visible": visible,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+	if err := resp.Err(); err != nil {
+		return err
+	}
+	jsonData, err := ioutil.ReadAll(resp)
+	if err != nil {
+		return err
+	}
+	var result interface{}
+	if err := json.Unmarshal(jsonData, &result); err != nil {
+		return NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	return nil
+}
+
 // PluginRegister executes plugin_register.
 func (db *DB) PluginRegister(name string) error {
 	resp, err := db.Invoke("plugin_register", map[string]interface{}{
@@ -1319,9 +1793,214 @@ func (db *DB) PluginUnregister(name string) error {
 	return db.recvBool(resp)
 }
 
-// Quit executes quit.
-func (db *DB) Quit() error {
-	resp, err := db.Invoke("quit", nil, nil)
+// DBQueryExpandOptions stores options for DB.QueryExpand.
+// http://groonga.org/docs/reference/commands/query_expand.html
+type DBQueryExpandOptions struct {
+	Flags              []string // --flags
+	TermColumn         string   // --term_column
+	ExpandedTermColumn string   // --expanded_term_column
+}
+
+// NewDBQueryExpandOptions returns the default DBQueryExpandOptions.
+func NewDBQueryExpandOptions() *DBQueryExpandOptions {
+	return &DBQueryExpandOptions{}
+}
+
+// QueryExpand executes query_expand.
+func (db *DB) QueryExpand(expander, query string, options *DBQueryExpandOptions) (string, error) {
+	if options == nil {
+		options = NewDBQueryExpandOptions()
+	}
+	params := map[string]interface{}{
+		"expander": expander,
+		"query":    query,
+	}
+	if options.Flags != nil {
+		params["flags"] = options.Flags
+	}
+	if options.TermColumn != "" {
+		params["term_column"] = options.TermColumn
+	}
+	if options.ExpandedTermColumn != "" {
+		params["expanded_term_column"] = options.ExpandedTermColumn
+	}
+	resp, err := db.Invoke("query_expand", params, nil)
+	if err != nil {
+		return "", err
+	}
+	return db.recvString(resp)
+}
+
+// splitQueryLogFlags splits pipe-separated query log flags.
+func splitQueryLogFlags(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, "|")
+}
+
+// QueryLogFlagsGet executes query_log_flags_get.
+func (db *DB) QueryLogFlagsGet() ([]string, error) {
+	resp, err := db.Invoke("query_log_flags_get", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	result, err := db.recvString(resp)
+	if err != nil {
+		return nil, err
+	}
+	return splitQueryLogFlags(result), nil
+}
+
+// DBQueryLogFlags is a result of query_log_flags_set, query_log_flags_add
+// and query_log_flags_remove.
+type DBQueryLogFlags struct {
+	Previous []string
+	Current  []string
+}
+
+// queryLogFlags executes query_log_flags_set, query_log_flags_add or
+// query_log_flags_remove.
+func (db *DB) queryLogFlags(name string, flags []string) (*DBQueryLogFlags, error) {
+	resp, err := db.Invoke(name, map[string]interface{}{
+		"flags": flags,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
+	jsonData, err := ioutil.ReadAll(resp)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Previous string `json:"previous"`
+		Current  string `json:"current"`
+	}
+	if err := json.Unmarshal(jsonData, &result); err != nil {
+		return nil, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	return &DBQueryLogFlags{
+		Previous: splitQueryLogFlags(result.Previous),
+		Current:  splitQueryLogFlags(result.Current),
+	}, nil
+}
+
+// QueryLogFlagsAdd executes query_log_flags_add.
+func (db *DB) QueryLogFlagsAdd(flags []string) (*DBQueryLogFlags, error) {
+	return db.queryLogFlags("query_log_flags_add", flags)
+}
+
+// QueryLogFlagsRemove executes query_log_flags_remove.
+func (db *DB) QueryLogFlagsRemove(flags []string) (*DBQueryLogFlags, error) {
+	return db.queryLogFlags("query_log_flags_remove", flags)
+}
+
+// QueryLogFlagsSet executes query_log_flags_set.
+func (db *DB) QueryLogFlagsSet(flags []string) (*DBQueryLogFlags, error) {
+	return db.queryLogFlags("query_log_flags_set", flags)
+}
+
+// Quit executes quit.
+func (db *DB) Quit() error {
+	resp, err := db.Invoke("quit", nil, nil)
+	if err != nil {
+		return err
+	}
+	return db.recvBool(resp)
+}
+
+// DBRangeFilterOptions stores options for DB.RangeFilter.
+type DBRangeFilterOptions struct {
+	Min           interface{} // --min
+	MinBorder     bool        // --min_border
+	Max           interface{} // --max
+	MaxBorder     bool        // --max_border
+	Offset        int         // --offset
+	Limit         int         // --limit
+	Filter        string      // --filter
+	OutputColumns []string    // --output_columns
+}
+
+// NewDBRangeFilterOptions returns the default DBRangeFilterOptions.
+func NewDBRangeFilterOptions() *DBRangeFilterOptions {
+	return &DBRangeFilterOptions{
+		MinBorder: true,
+		MaxBorder: true,
+		Limit:     10,
+	}
+}
+
+// RangeFilter executes range_filter.
+// On success, it is the caller's responsibility to close the result.
+func (db *DB) RangeFilter(tbl, column string, options *DBRangeFilterOptions) (io.ReadCloser, error) {
+	if options == nil {
+		options = NewDBRangeFilterOptions()
+	}
+	params := map[string]interface{}{
+		"table":  tbl,
+		"column": column,
+	}
+	if options.Min != nil {
+		params["min"] = options.Min
+	}
+	params["min_border"] = options.MinBorder
+	if options.Max != nil {
+		params["max"] = options.Max
+	}
+	params["max_border"] = options.MaxBorder
+	if options.Offset != 0 {
+		params["offset"] = options.Offset
+	}
+	if options.Limit != 10 {
+		params["limit"] = options.Limit
+	}
+	if options.Filter != "" {
+		params["filter"] = options.Filter
+	}
+	if options.OutputColumns != nil {
+		params["output_columns"] = options.OutputColumns
+	}
+	resp, err := db.Invoke("range_filter", params, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Err(); err != nil {
+		resp.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// referenceParams returns parameters of reference_acquire and reference_release.
+func referenceParams(target string, recursive bool) map[string]interface{} {
+	params := map[string]interface{}{
+		"recursive": recursive,
+	}
+	if target != "" {
+		params["target_name"] = target
+	}
+	return params
+}
+
+// ReferenceAcquire executes reference_acquire.
+// If target is empty, all the objects are referred.
+func (db *DB) ReferenceAcquire(target string, recursive bool) error {
+	resp, err := db.Invoke("reference_acquire", referenceParams(target, recursive), nil)
+	if err != nil {
+		return err
+	}
+	return db.recvBool(resp)
+}
+
+// ReferenceRelease executes reference_release.
+func (db *DB) ReferenceRelease(target string, recursive bool) error {
+	resp, err := db.Invoke("reference_release", referenceParams(target, recursive), nil)
 	if err != nil {
 		return err
 	}
@@ -1619,7 +2298,6 @@ func NewDBSelectOptionsColumn() *DBSelectOptionsColumn {
 
 // setParams sets options to params.
 func (options *DBSelectOptionsColumn) setParams(prefix string, params map[string]interface{}) {
-	// FIXME: slice options are not supported.
 	params[prefix+".stage"] = options.Stage
 	if options.Flags != nil {
 		params[prefix+".flags"] = options.Flags
@@ -1656,7 +2334,6 @@ func NewDBSelectOptionsDrilldown() *DBSelectOptionsDrilldown {
 
 // setParams sets options to params.
 func (options *DBSelectOptionsDrilldown) setParams(prefix string, params map[string]interface{}) {
-	// FIXME: slice options are not supported.
 	params[prefix+".keys"] = options.Keys
 	if options.SortKeys != nil {
 		params[prefix+".sort_keys"] = options.SortKeys
@@ -1676,6 +2353,53 @@ func (options *DBSelectOptionsDrilldown) setParams(prefix string, params map[str
 	}
 }
 
+// DBSelectOptionsSlice stores --slices[LABEL].
+type DBSelectOptionsSlice struct {
+	MatchColumns  []string // --slices[LABEL].match_columns
+	Query         string   // --slices[LABEL].query
+	QueryFlags    []string // --slices[LABEL].query_flags
+	Filter        string   // --slices[LABEL].filter
+	SortKeys      []string // --slices[LABEL].sort_keys
+	OutputColumns []string // --slices[LABEL].output_columns
+	Offset        int      // --slices[LABEL].offset
+	Limit         int      // --slices[LABEL].limit
+	Drilldowns    map[string]*DBSelectOptionsDrilldown
+}
+
+// NewDBSelectOptionsSlice returns the default DBSelectOptionsSlice.
+func NewDBSelectOptionsSlice() *DBSelectOptionsSlice {
+	return &DBSelectOptionsSlice{
+		Limit: 10,
+	}
+}
+
+// setParams sets options to params.
+func (options *DBSelectOptionsSlice) setParams(prefix string, params map[string]interface{}) {
+	if options.MatchColumns != nil {
+		params[prefix+".match_columns"] = options.MatchColumns
+	}
+	if options.Query != "" {
+		params[prefix+".query"] = options.Query
+	}
+	if options.QueryFlags != nil {
+		params[prefix+".query_flags"] = options.QueryFlags
+	}
+	if options.Filter != "" {
+		params[prefix+".filter"] = options.Filter
+	}
+	if options.SortKeys != nil {
+		params[prefix+".sort_keys"] = options.SortKeys
+	}
+	if options.OutputColumns != nil {
+		params[prefix+".output_columns"] = options.OutputColumns
+	}
+	params[prefix+".offset"] = options.Offset
+	params[prefix+".limit"] = options.Limit
+	for label, drilldown := range options.Drilldowns {
+		drilldown.setParams(prefix+".drilldowns["+label+"]", params)
+	}
+}
+
 // DBSelectOptions stores options for DB.Select.
 // http://groonga.org/docs/reference/commands/select.html
 type DBSelectOptions struct {
@@ -1703,6 +2427,7 @@ type DBSelectOptions struct {
 	DrilldownFilter          string   // --drilldown_filter
 	Columns                  map[string]*DBSelectOptionsColumn
 	Drilldowns               map[string]*DBSelectOptionsDrilldown
+	Slices                   map[string]*DBSelectOptionsSlice
 }
 
 // NewDBSelectOptions returns the default DBSelectOptions.
@@ -1793,10 +2518,13 @@ func (db *DB) Select(tbl string, options *DBSelectOptions) (io.ReadCloser, error
 		params["drilldown_filter"] = options.DrilldownFilter
 	}
 	for name, col := range options.Columns {
-		col.setParams("--columns["+name+"]", params)
+		col.setParams("columns["+name+"]", params)
 	}
 	for label, drilldown := range options.Drilldowns {
-		drilldown.setParams("--drilldowns["+label+"]", params)
+		drilldown.setParams("drilldowns["+label+"]", params)
+	}
+	for label, slice := range options.Slices {
+		slice.setParams("slices["+label+"]", params)
 	}
 	resp, err := db.Invoke("select", params, nil)
 	if err != nil {
@@ -1817,18 +2545,23 @@ func (db *DB) parseRows(rows interface{}, data []byte, cfs []*ColumnField) (int,
 			"error": err.Error(),
 		})
 	}
+	return db.decodeRows(rows, raw[0], cfs)
+}
 
+// decodeRows decodes a record set into rows.
+// Columns associated with nil in cfs are skipped.
+func (db *DB) decodeRows(rows interface{}, set [][]json.RawMessage, cfs []*ColumnField) (int, error) {
 	var nHits int
-	if err := json.Unmarshal(raw[0][0][0], &nHits); err != nil {
+	if err := json.Unmarshal(set[0][0], &nHits); err != nil {
 		return 0, err
 	}
 
-	rawCols := raw[0][1]
+	rawCols := set[1]
 	nCols := len(rawCols)
 	if nCols != len(cfs) {
 		// Remove _score from fields if _score does not exist in the response.
 		for i, cf := range cfs {
-			if cf.Name == "_score" {
+			if cf != nil && cf.Name == "_score" {
 				hasScore := false
 				for _, rawCol := range rawCols {
 					var nameType []string
@@ -1870,7 +2603,7 @@ func (db *DB) parseRows(rows interface{}, data []byte, cfs []*ColumnField) (int,
 	//		}
 	//	}
 
-	rawRecs := raw[0][2:]
+	rawRecs := set[2:]
 	nRecs := len(rawRecs)
 
 	recs := reflect.ValueOf(rows).Elem()
@@ -1878,6 +2611,9 @@ func (db *DB) parseRows(rows interface{}, data []byte, cfs []*ColumnField) (int,
 	for i := 0; i < nRecs; i++ {
 		rec := recs.Index(i)
 		for j, cf := range cfs {
+			if cf == nil {
+				continue
+			}
 			ptr := rec.Field(cf.Index).Addr()
 			switch v := ptr.Interface().(type) {
 			case *bool:
@@ -1971,7 +2707,7 @@ func (db *DB) parseRows(rows interface{}, data []byte, cfs []*ColumnField) (int,
 						"error": err.Error(),
 					})
 				}
-				*v = time.Unix(int64(f), int64(f*1000000)%1000000)
+				*v = floatToTime(f)
 			case *[]bool:
 				if err := json.Unmarshal(rawRecs[i][j], v); err != nil {
 					return 0, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
@@ -2065,7 +2801,7 @@ func (db *DB) parseRows(rows interface{}, data []byte, cfs []*ColumnField) (int,
 				}
 				*v = make([]time.Time, len(f))
 				for i := range f {
-					(*v)[i] = time.Unix(int64(f[i]), int64(f[i]*1000000)%1000000)
+					(*v)[i] = floatToTime(f[i])
 				}
 			}
 		}
@@ -2074,6 +2810,483 @@ func (db *DB) parseRows(rows interface{}, data []byte, cfs []*ColumnField) (int,
 
 }
 
+// DBRecordColumn is a column of DBRecords.
+type DBRecordColumn struct {
+	Name string // Column name
+	Type string // Groonga type name (built-in type or table name)
+}
+
+// DBRecord is a record of DBRecords.
+// Values are converted according to the Groonga types as follows:
+//
+//  Bool -> bool
+//  Int8, Int16, Int32, Int64 -> int8, int16, int32, int64
+//  UInt8, UInt16, UInt32, UInt64 -> uint8, uint16, uint32, uint64
+//  Float32, Float -> float32, float64
+//  ShortText, Text, LongText -> string
+//  Time -> time.Time
+//  WGS84GeoPoint, TokyoGeoPoint -> Geo
+//
+// A vector is converted to a slice of the above types and
+// a reference is converted to the key of the referenced record.
+// Values of unknown types are left as generic JSON values.
+type DBRecord map[string]interface{}
+
+// DBRecords is a response of select without tagged structs.
+type DBRecords struct {
+	NHits   int              // Number of hits
+	Columns []DBRecordColumn // Output columns
+	Records []DBRecord       // Records
+}
+
+// dbRecordTypes maps built-in types to Go types.
+var dbRecordTypes = map[string]reflect.Type{
+	"Bool":          reflect.TypeOf(false),
+	"Int8":          reflect.TypeOf(int8(0)),
+	"Int16":         reflect.TypeOf(int16(0)),
+	"Int32":         reflect.TypeOf(int32(0)),
+	"Int64":         reflect.TypeOf(int64(0)),
+	"UInt8":         reflect.TypeOf(uint8(0)),
+	"UInt16":        reflect.TypeOf(uint16(0)),
+	"UInt32":        reflect.TypeOf(uint32(0)),
+	"UInt64":        reflect.TypeOf(uint64(0)),
+	"Float32":       reflect.TypeOf(float32(0)),
+	"Float":         reflect.TypeOf(float64(0)),
+	"ShortText":     reflect.TypeOf(""),
+	"Text":          reflect.TypeOf(""),
+	"LongText":      reflect.TypeOf(""),
+	"Time":          reflect.TypeOf(time.Time{}),
+	"WGS84GeoPoint": reflect.TypeOf(Geo{}),
+	"TokyoGeoPoint": reflect.TypeOf(Geo{}),
+}
+
+// parseGeo parses a point formatted as "LATxLONG" or "LAT,LONG".
+// Values in degrees are converted into milliseconds.
+func parseGeo(s string) (Geo, error) {
+	i := strings.IndexAny(s, "x,")
+	if i == -1 {
+		return Geo{}, NewError(ResponseError, "The point must contain 'x' or ','.", map[string]interface{}{
+			"point": s,
+		})
+	}
+	var g Geo
+	for j, v := range []string{s[:i], s[i+1:]} {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return Geo{}, NewError(ResponseError, "strconv.ParseFloat failed.", map[string]interface{}{
+				"point": s,
+				"error": err.Error(),
+			})
+		}
+		if strings.IndexByte(v, '.') != -1 {
+			f *= 60 * 60 * 1000
+		}
+		if j == 0 {
+			g.Lat = int32(math.Floor(f + 0.5))
+		} else {
+			g.Long = int32(math.Floor(f + 0.5))
+		}
+	}
+	return g, nil
+}
+
+// floatToTime converts seconds since the Unix epoch into time.Time.
+// The fractional part is rounded to microseconds.
+func floatToTime(f float64) time.Time {
+	sec := math.Floor(f)
+	usec := int64(math.Floor((f-sec)*1000000 + 0.5))
+	return time.Unix(int64(sec), usec*1000)
+}
+
+// decodeRecordValue decodes a value of typ.
+// typ must be a key of dbRecordTypes.
+func (db *DB) decodeRecordValue(data json.RawMessage, typ reflect.Type) (reflect.Value, error) {
+	switch typ {
+	case dbRecordTypes["Time"]:
+		var f float64
+		if err := json.Unmarshal(data, &f); err != nil {
+			return reflect.Value{}, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		return reflect.ValueOf(floatToTime(f)), nil
+	case dbRecordTypes["WGS84GeoPoint"]:
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return reflect.Value{}, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		g, err := parseGeo(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(g), nil
+	}
+	ptr := reflect.New(typ)
+	if err := json.Unmarshal(data, ptr.Interface()); err != nil {
+		return reflect.Value{}, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	return ptr.Elem(), nil
+}
+
+// decodeRecord decodes a value of typ.
+// If typ is nil, the value is decoded as a generic JSON value.
+func (db *DB) decodeRecord(data json.RawMessage, typ reflect.Type) (interface{}, error) {
+	data = bytes.TrimSpace(data)
+	if typ == nil || len(data) == 0 || data[0] == '{' {
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		return v, nil
+	}
+	switch data[0] {
+	case 'n':
+		return nil, nil
+	case '[':
+		var elems []json.RawMessage
+		if err := json.Unmarshal(data, &elems); err != nil {
+			return nil, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		vec := reflect.MakeSlice(reflect.SliceOf(typ), len(elems), len(elems))
+		for i, elem := range elems {
+			v, err := db.decodeRecordValue(elem, typ)
+			if err != nil {
+				return nil, err
+			}
+			vec.Index(i).Set(v)
+		}
+		return vec.Interface(), nil
+	}
+	v, err := db.decodeRecordValue(data, typ)
+	if err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// recordTypes returns the Go types associated with columns.
+// The schema is read only if columns contain references.
+func (db *DB) recordTypes(cols []DBRecordColumn) ([]reflect.Type, error) {
+	types := make([]reflect.Type, len(cols))
+	var schema *DBSchema
+	for i, col := range cols {
+		name := col.Type
+		if schema == nil {
+			if _, ok := dbRecordTypes[name]; ok || name == "" || name == "Object" {
+				types[i] = dbRecordTypes[name]
+				continue
+			}
+			var err error
+			if schema, err = db.Schema(); err != nil {
+				return nil, err
+			}
+		}
+		// Follow references until a built-in type is found.
+		for j := 0; j < len(schema.Tables); j++ {
+			tbl, ok := schema.Tables[name]
+			if !ok {
+				break
+			}
+			if tbl.KeyType == nil {
+				name = "UInt32"
+				break
+			}
+			name = tbl.KeyType.Name
+		}
+		types[i] = dbRecordTypes[name]
+	}
+	return types, nil
+}
+
+// parseRecords parses a record set.
+func (db *DB) parseRecords(raw [][]json.RawMessage) (*DBRecords, error) {
+	if len(raw) < 2 || len(raw[0]) == 0 {
+		return nil, NewError(ResponseError, "The record set is broken.", map[string]interface{}{
+			"nElems": len(raw),
+		})
+	}
+	var result DBRecords
+	if err := json.Unmarshal(raw[0][0], &result.NHits); err != nil {
+		return nil, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	for _, rawCol := range raw[1] {
+		var nameType []*string
+		if err := json.Unmarshal(rawCol, &nameType); err != nil {
+			return nil, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		var col DBRecordColumn
+		if len(nameType) > 0 && nameType[0] != nil {
+			col.Name = *nameType[0]
+		}
+		if len(nameType) > 1 && nameType[1] != nil {
+			col.Type = *nameType[1]
+		}
+		result.Columns = append(result.Columns, col)
+	}
+	types, err := db.recordTypes(result.Columns)
+	if err != nil {
+		return nil, err
+	}
+	result.Records = make([]DBRecord, len(raw)-2)
+	for i, rawRec := range raw[2:] {
+		if len(rawRec) != len(result.Columns) {
+			return nil, NewError(ResponseError, "nValues and nColumns must be same.", map[string]interface{}{
+				"nValues": len(rawRec),
+				"nCols":   len(result.Columns),
+			})
+		}
+		rec := make(DBRecord, len(rawRec))
+		for j, col := range result.Columns {
+			v, err := db.decodeRecord(rawRec[j], types[j])
+			if err != nil {
+				if e, ok := err.(*Error); ok {
+					e.Data["column"] = col.Name
+					e.Data["type"] = col.Type
+				}
+				return nil, err
+			}
+			rec[col.Name] = v
+		}
+		result.Records[i] = rec
+	}
+	return &result, nil
+}
+
+// SelectRecords executes select and returns records without tagged structs.
+func (db *DB) SelectRecords(tbl string, options *DBSelectOptions) (*DBRecords, error) {
+	result, err := db.Select(tbl, options)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+	data, err := ioutil.ReadAll(result)
+	if err != nil {
+		return nil, err
+	}
+	var raw [][][]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	if len(raw) == 0 {
+		return nil, NewError(ResponseError, "The response is empty.", nil)
+	}
+	return db.parseRecords(raw[0])
+}
+
+// DBSelectResult is a response of select including drilldowns and slices.
+type DBSelectResult struct {
+	db         *DB
+	NHits      int                            // Number of hits
+	Records    *DBRecords                     // Main result
+	Drilldowns map[string]*DBRecords          // Drilldown results by key (--drilldown) or label (--drilldowns)
+	Slices     map[string]*DBSelectResult     // Slice results by label (--slices)
+	raw        [][]json.RawMessage            // Raw main result
+	rawLabels  map[string][][]json.RawMessage // Raw drilldown results
+}
+
+// newSelectResult returns a new DBSelectResult.
+func (db *DB) newSelectResult(set [][]json.RawMessage) (*DBSelectResult, error) {
+	records, err := db.parseRecords(set)
+	if err != nil {
+		return nil, err
+	}
+	return &DBSelectResult{
+		db:         db,
+		NHits:      records.NHits,
+		Records:    records,
+		Drilldowns: make(map[string]*DBRecords),
+		Slices:     make(map[string]*DBSelectResult),
+		raw:        set,
+		rawLabels:  make(map[string][][]json.RawMessage),
+	}, nil
+}
+
+// addDrilldown adds a drilldown result.
+func (r *DBSelectResult) addDrilldown(label string, data json.RawMessage) error {
+	var set [][]json.RawMessage
+	if err := json.Unmarshal(data, &set); err != nil {
+		return NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+			"label": label,
+			"error": err.Error(),
+		})
+	}
+	records, err := r.db.parseRecords(set)
+	if err != nil {
+		if e, ok := err.(*Error); ok {
+			e.Data["label"] = label
+		}
+		return err
+	}
+	r.Drilldowns[label] = records
+	r.rawLabels[label] = set
+	return nil
+}
+
+// addSlice adds a slice result.
+// A slice result is a record set optionally followed by labeled drilldown results.
+func (r *DBSelectResult) addSlice(label string, data json.RawMessage) error {
+	var elems []json.RawMessage
+	if err := json.Unmarshal(data, &elems); err != nil {
+		return NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+			"label": label,
+			"error": err.Error(),
+		})
+	}
+	var set [][]json.RawMessage
+	var drilldowns []map[string]json.RawMessage
+	for _, elem := range elems {
+		elem = bytes.TrimSpace(elem)
+		if len(elem) != 0 && elem[0] == '{' {
+			var m map[string]json.RawMessage
+			if err := json.Unmarshal(elem, &m); err != nil {
+				return NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+					"label": label,
+					"error": err.Error(),
+				})
+			}
+			drilldowns = append(drilldowns, m)
+			continue
+		}
+		var v []json.RawMessage
+		if err := json.Unmarshal(elem, &v); err != nil {
+			return NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+				"label": label,
+				"error": err.Error(),
+			})
+		}
+		set = append(set, v)
+	}
+	slice, err := r.db.newSelectResult(set)
+	if err != nil {
+		if e, ok := err.(*Error); ok {
+			e.Data["slice"] = label
+		}
+		return err
+	}
+	for _, m := range drilldowns {
+		for label, data := range m {
+			if err := slice.addDrilldown(label, data); err != nil {
+				return err
+			}
+		}
+	}
+	r.Slices[label] = slice
+	return nil
+}
+
+// Decode decodes the result specified by label into rows.
+// If label is empty, the main result is decoded.
+// Otherwise, label must be a key of --drilldown or a label of --drilldowns.
+// Use Slices[LABEL].Decode for slice results.
+// The fields of rows are associated with output columns by name and
+// output columns without an associated field are ignored.
+func (r *DBSelectResult) Decode(label string, rows interface{}) (int, error) {
+	set := r.raw
+	records := r.Records
+	if label != "" {
+		var ok bool
+		if set, ok = r.rawLabels[label]; !ok {
+			return 0, NewError(CommandError, "The drilldown does not exist.", map[string]interface{}{
+				"label": label,
+			})
+		}
+		records = r.Drilldowns[label]
+	}
+	rs, err := GetRowStruct(rows)
+	if err != nil {
+		return 0, err
+	}
+	cfs := make([]*ColumnField, len(records.Columns))
+	for i, col := range records.Columns {
+		cfs[i] = rs.ColumnsByName[col.Name]
+	}
+	return r.db.decodeRows(rows, set, cfs)
+}
+
+// SelectResult executes select and returns the main result, drilldown results and slice results.
+func (db *DB) SelectResult(tbl string, options *DBSelectOptions) (*DBSelectResult, error) {
+	if options == nil {
+		options = NewDBSelectOptions()
+	}
+	result, err := db.Select(tbl, options)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+	data, err := ioutil.ReadAll(result)
+	if err != nil {
+		return nil, err
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	if len(raw) == 0 {
+		return nil, NewError(ResponseError, "The response is empty.", nil)
+	}
+	var set [][]json.RawMessage
+	if err := json.Unmarshal(raw[0], &set); err != nil {
+		return nil, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	r, err := db.newSelectResult(set)
+	if err != nil {
+		return nil, err
+	}
+	// Legacy drilldown results are arrays in the order of --drilldown.
+	// Slice results and labeled drilldown results are objects keyed by label.
+	i := 0
+	for _, elem := range raw[1:] {
+		elem = bytes.TrimSpace(elem)
+		if len(elem) != 0 && elem[0] == '{' {
+			var m map[string]json.RawMessage
+			if err := json.Unmarshal(elem, &m); err != nil {
+				return nil, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+					"error": err.Error(),
+				})
+			}
+			for label, data := range m {
+				if _, ok := options.Slices[label]; ok {
+					err = r.addSlice(label, data)
+				} else {
+					err = r.addDrilldown(label, data)
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if i >= len(options.Drilldown) {
+			return nil, NewError(ResponseError, "The number of drilldown results is too large.", map[string]interface{}{
+				"drilldown": options.Drilldown,
+			})
+		}
+		if err := r.addDrilldown(options.Drilldown[i], elem); err != nil {
+			return nil, err
+		}
+		i++
+	}
+	return r, nil
+}
+
 // SelectRows executes select.
 func (db *DB) SelectRows(tbl string, rows interface{}, options *DBSelectOptions) (int, error) {
 	if options == nil {
@@ -2230,6 +3443,7 @@ type DBTableCreateOptions struct {
 	DefaultTokenizer string   // --default_tokenizer
 	Normalizer       string   // --normalizer
 	TokenFilters     []string // --token_filters
+	Path             string   // --path
 }
 
 // NewDBTableCreateOptions returns the default DBTableCreateOptions.
@@ -2305,6 +3519,9 @@ func (db *DB) TableCreate(name string, options *DBTableCreateOptions) error {
 	if options.TokenFilters != nil {
 		params["token_filters"] = options.TokenFilters
 	}
+	if options.Path != "" {
+		params["path"] = options.Path
+	}
 	resp, err := db.Invoke("table_create", params, nil)
 	if err != nil {
 		return err
@@ -2481,6 +3698,16 @@ func (db *DB) TableTokenize(tbl, str string, options *DBTableTokenizeOptions) ([
 	return result, nil
 }
 
+// ThreadDump executes thread_dump.
+// The backtraces are written to the Groonga log.
+func (db *DB) ThreadDump() error {
+	resp, err := db.Invoke("thread_dump", nil, nil)
+	if err != nil {
+		return err
+	}
+	return db.recvBool(resp)
+}
+
 // ThreadLimit executes thread_limit.
 // If max < 0, max is not passed to thread_limit.
 func (db *DB) ThreadLimit(max int) (int, error) {
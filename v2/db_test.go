@@ -0,0 +1,376 @@
+package grnci
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDBSelectRecords(t *testing.T) {
+	h := newTestHandler(func(cmd *Command, body string) (string, error) {
+		switch cmd.Name() {
+		case "select":
+			return `[[[2],
+[["_id","UInt32"],["_key","ShortText"],["time","Time"],["point","WGS84GeoPoint"],["tags","ShortText"],["user","Users"],["score","Float"]],
+[1,"a",1234567890.123456,"35681396x139766049",["x","y"],"alice",1.5],
+[2,"b",0.0,"35.5x139.5",[],null,2]]]`, nil
+		case "schema":
+			return `{"tables":{"Users":{"name":"Users","type":"hash table","key_type":{"name":"ShortText","type":"type"}}}}`, nil
+		}
+		return "true", nil
+	})
+	db := NewDB(h)
+	result, err := db.SelectRecords("Tbl", nil)
+	if err != nil {
+		t.Fatalf("db.SelectRecords failed: %v", err)
+	}
+	if result.NHits != 2 {
+		t.Fatalf("db.SelectRecords failed: NHits = %d, want = %d", result.NHits, 2)
+	}
+	if actual, want := result.Columns[5], (DBRecordColumn{Name: "user", Type: "Users"}); actual != want {
+		t.Fatalf("db.SelectRecords failed: Columns[5] = %#v, want = %#v", actual, want)
+	}
+	want := []DBRecord{
+		{
+			"_id":   uint32(1),
+			"_key":  "a",
+			"time":  time.Unix(1234567890, 123456000),
+			"point": Geo{Lat: 35681396, Long: 139766049},
+			"tags":  []string{"x", "y"},
+			"user":  "alice",
+			"score": 1.5,
+		},
+		{
+			"_id":   uint32(2),
+			"_key":  "b",
+			"time":  time.Unix(0, 0),
+			"point": Geo{Lat: 127800000, Long: 502200000},
+			"tags":  []string{},
+			"user":  nil,
+			"score": 2.0,
+		},
+	}
+	if !reflect.DeepEqual(result.Records, want) {
+		t.Fatalf("db.SelectRecords failed: actual = %#v, want = %#v", result.Records, want)
+	}
+}
+
+func TestDBSelectResult(t *testing.T) {
+	h := newTestHandler(func(cmd *Command, body string) (string, error) {
+		return `[[[3],[["_id","UInt32"],["tag","ShortText"]],[1,"a"],[2,"b"],[3,"a"]],
+[[2],[["_key","ShortText"],["_nsubrecs","Int32"]],["a",2],["b",1]],
+{"by_tag":[[2],[["_key","ShortText"],["_nsubrecs","Int32"],["_max","Int64"],["_avg","Float"]],["a",2,30,20.5],["b",1,10,10.0]]}]`, nil
+	})
+	db := NewDB(h)
+	options := NewDBSelectOptions()
+	options.Drilldown = []string{"tag"}
+	options.Drilldowns = map[string]*DBSelectOptionsDrilldown{
+		"by_tag": NewDBSelectOptionsDrilldown(),
+	}
+	options.Drilldowns["by_tag"].Keys = []string{"tag"}
+	result, err := db.SelectResult("Tbl", options)
+	if err != nil {
+		t.Fatalf("db.SelectResult failed: %v", err)
+	}
+	params := h.commands[0].Params()
+	if actual, want := params["drilldown"], "tag"; actual != want {
+		t.Fatalf("db.SelectResult failed: drilldown = %s, want = %s", actual, want)
+	}
+	if actual, want := params["drilldowns[by_tag].keys"], "tag"; actual != want {
+		t.Fatalf("db.SelectResult failed: drilldowns[by_tag].keys = %s, want = %s", actual, want)
+	}
+	if result.NHits != 3 || len(result.Records.Records) != 3 {
+		t.Fatalf("db.SelectResult failed: NHits = %d, nRecords = %d", result.NHits, len(result.Records.Records))
+	}
+	if actual, want := result.Drilldowns["tag"].Records[0]["_nsubrecs"], int32(2); actual != want {
+		t.Fatalf("db.SelectResult failed: actual = %#v, want = %#v", actual, want)
+	}
+	if actual, want := result.Drilldowns["by_tag"].Records[0]["_avg"], 20.5; actual != want {
+		t.Fatalf("db.SelectResult failed: actual = %#v, want = %#v", actual, want)
+	}
+
+	type Group struct {
+		Key      string  `grnci:"_key"`
+		NSubRecs int     `grnci:"_nsubrecs"`
+		Max      int64   `grnci:"_max"`
+		Avg      float64 `grnci:"_avg"`
+	}
+	var groups []Group
+	n, err := result.Decode("by_tag", &groups)
+	if err != nil {
+		t.Fatalf("result.Decode failed: %v", err)
+	}
+	want := []Group{{"a", 2, 30, 20.5}, {"b", 1, 10, 10}}
+	if n != 2 || !reflect.DeepEqual(groups, want) {
+		t.Fatalf("result.Decode failed: actual = %#v, want = %#v", groups, want)
+	}
+	type Row struct {
+		ID  uint32 `grnci:"_id"`
+		Tag string `grnci:"tag"`
+	}
+	var rows []Row
+	if _, err := result.Decode("", &rows); err != nil {
+		t.Fatalf("result.Decode failed: %v", err)
+	}
+	if actual, want := rows[2], (Row{3, "a"}); actual != want {
+		t.Fatalf("result.Decode failed: actual = %#v, want = %#v", actual, want)
+	}
+	if _, err := result.Decode("unknown", &rows); err == nil {
+		t.Fatalf("result.Decode succeeded for an unknown label")
+	}
+}
+
+func TestDBSelectParams(t *testing.T) {
+	h := newTestHandler(nil)
+	db := NewDB(h)
+	column := &DBSelectOptionsColumn{Stage: "initial", Type: "Int32", Value: "1"}
+	drilldown := NewDBSelectOptionsDrilldown()
+	drilldown.Keys = []string{"tag"}
+	drilldown.Columns = map[string]*DBSelectOptionsColumn{"y": column}
+	options := NewDBSelectOptions()
+	options.Drilldown = []string{"a", "b"}
+	options.Columns = map[string]*DBSelectOptionsColumn{"x": column}
+	options.Drilldowns = map[string]*DBSelectOptionsDrilldown{"by_tag": drilldown}
+	r, err := db.Select("Tbl", options)
+	if err != nil {
+		t.Fatalf("db.Select failed: %v", err)
+	}
+	r.Close()
+	lsOptions := NewDBLogicalSelectOptions()
+	lsOptions.Drilldown = options.Drilldown
+	lsOptions.Columns = options.Columns
+	lsOptions.Drilldowns = options.Drilldowns
+	r, err = db.LogicalSelect("Logs", "time", lsOptions)
+	if err != nil {
+		t.Fatalf("db.LogicalSelect failed: %v", err)
+	}
+	r.Close()
+	want := map[string]string{
+		"drilldown":                          "a,b",
+		"columns[x].stage":                   "initial",
+		"columns[x].type":                    "Int32",
+		"columns[x].value":                   "1",
+		"drilldowns[by_tag].keys":            "tag",
+		"drilldowns[by_tag].columns[y].type": "Int32",
+	}
+	for _, cmd := range h.commands {
+		params := cmd.Params()
+		for k, v := range want {
+			if params[k] != v {
+				t.Fatalf("db.%s failed: %s = %s, want = %s", cmd.Name(), k, params[k], v)
+			}
+		}
+		if s := cmd.String(); strings.Contains(s, "----") {
+			t.Fatalf("db.%s failed: command = %s", cmd.Name(), s)
+		}
+	}
+}
+
+func TestDBSelectResultSlices(t *testing.T) {
+	h := newTestHandler(func(cmd *Command, body string) (string, error) {
+		return `[[[3],[["_id","UInt32"]],[1],[2],[3]],
+{"popular":[[1],[["_id","UInt32"],["tag","ShortText"]],[2,"a"],{"by_tag":[[1],[["_key","ShortText"],["_nsubrecs","Int32"]],["a",1]]}]},
+{"by_id":[[3],[["_key","UInt32"],["_nsubrecs","Int32"]],[1,1],[2,1],[3,1]]}]`, nil
+	})
+	db := NewDB(h)
+	options := NewDBSelectOptions()
+	slice := NewDBSelectOptionsSlice()
+	slice.MatchColumns = []string{"title", "body"}
+	slice.Query = "groonga"
+	slice.Drilldowns = map[string]*DBSelectOptionsDrilldown{
+		"by_tag": NewDBSelectOptionsDrilldown(),
+	}
+	slice.Drilldowns["by_tag"].Keys = []string{"tag"}
+	options.Slices = map[string]*DBSelectOptionsSlice{"popular": slice}
+	result, err := db.SelectResult("Tbl", options)
+	if err != nil {
+		t.Fatalf("db.SelectResult failed: %v", err)
+	}
+	params := h.commands[0].Params()
+	if actual, want := params["slices[popular].match_columns"], "title||body"; actual != want {
+		t.Fatalf("db.SelectResult failed: match_columns = %s, want = %s", actual, want)
+	}
+	if actual, want := params["slices[popular].drilldowns[by_tag].keys"], "tag"; actual != want {
+		t.Fatalf("db.SelectResult failed: keys = %s, want = %s", actual, want)
+	}
+	popular, ok := result.Slices["popular"]
+	if !ok {
+		t.Fatalf("db.SelectResult failed: no slice result")
+	}
+	if actual, want := popular.Records.Records[0]["tag"], "a"; actual != want {
+		t.Fatalf("db.SelectResult failed: actual = %#v, want = %#v", actual, want)
+	}
+	if actual, want := popular.Drilldowns["by_tag"].Records[0]["_nsubrecs"], int32(1); actual != want {
+		t.Fatalf("db.SelectResult failed: actual = %#v, want = %#v", actual, want)
+	}
+	if actual, want := result.Drilldowns["by_id"].NHits, 3; actual != want {
+		t.Fatalf("db.SelectResult failed: actual = %d, want = %d", actual, want)
+	}
+}
+
+func TestDBLogicalRangeFilter(t *testing.T) {
+	h := newTestHandler(func(cmd *Command, body string) (string, error) {
+		return `[[[["_id","UInt32"],["timestamp","Time"],["message","Text"]],[1,1500000000.0,"a"],[2,1500000001.5,"b"]]]`, nil
+	})
+	db := NewDB(h)
+	options := NewDBLogicalRangeFilterOptions()
+	options.Min = time.Unix(1500000000, 0)
+	options.MaxBorder = false
+	options.Order = "descending"
+	options.Cache = false
+	type Log struct {
+		ID        uint32    `grnci:"_id"`
+		Timestamp time.Time `grnci:"timestamp"`
+		Message   string    `grnci:"message;Text"`
+	}
+	var logs []Log
+	n, err := db.LogicalRangeFilterRows("Logs", "timestamp", &logs, options)
+	if err != nil {
+		t.Fatalf("db.LogicalRangeFilterRows failed: %v", err)
+	}
+	if n != 2 || logs[1].Message != "b" {
+		t.Fatalf("db.LogicalRangeFilterRows failed: n = %d, logs = %#v", n, logs)
+	}
+	params := h.commands[0].Params()
+	want := map[string]string{
+		"min":            "1500000000",
+		"max_border":     "exclude",
+		"order":          "descending",
+		"cache":          "no",
+		"output_columns": "_id,timestamp,message",
+	}
+	for k, v := range want {
+		if params[k] != v {
+			t.Fatalf("db.LogicalRangeFilterRows failed: %s = %s, want = %s", k, params[k], v)
+		}
+	}
+
+	r, err := db.LogicalRangeFilterStream("Logs", "timestamp", nil)
+	if err != nil {
+		t.Fatalf("db.LogicalRangeFilterStream failed: %v", err)
+	}
+	defer r.Close()
+	rec, err := r.Next()
+	if err != nil {
+		t.Fatalf("r.Next failed: %v", err)
+	}
+	if actual, want := rec["timestamp"], time.Unix(1500000000, 0); actual != want {
+		t.Fatalf("r.Next failed: actual = %v, want = %v", actual, want)
+	}
+	var log Log
+	for _, row := range []interface{}{nil, log, (*Log)(nil), new(int)} {
+		if err := r.NextRow(row); err == nil {
+			t.Fatalf("r.NextRow succeeded: row = %#v", row)
+		} else if e, ok := err.(*Error); !ok || e.Code != CommandError {
+			t.Fatalf("r.NextRow failed: %v", err)
+		}
+	}
+	if err := r.NextRow(&log); err != nil {
+		t.Fatalf("r.NextRow failed: %v", err)
+	}
+	if actual, want := log, (Log{2, time.Unix(1500000001, 500000000), "b"}); actual != want {
+		t.Fatalf("r.NextRow failed: actual = %#v, want = %#v", actual, want)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("r.Next failed: err = %v", err)
+	}
+}
+
+func TestDBIndexColumnDiff(t *testing.T) {
+	h := newTestHandler(func(cmd *Command, body string) (string, error) {
+		return `[{"token":{"id":1,"value":"a"},"remains":[{"record_id":2,"position":3}],"missings":[]}]`, nil
+	})
+	db := NewDB(h)
+	result, err := db.IndexColumnDiff("Terms.index")
+	if err != nil {
+		t.Fatalf("db.IndexColumnDiff failed: %v", err)
+	}
+	if actual, want := h.commands[0].String(), "index_column_diff --name 'index' --table 'Terms'"; actual != want {
+		t.Fatalf("db.IndexColumnDiff failed: actual = %s, want = %s", actual, want)
+	}
+	want := []DBIndexColumnDiff{{
+		Token:    DBIndexColumnDiffToken{ID: 1, Value: "a"},
+		Remains:  []DBIndexColumnDiffPosting{{RecordID: 2, Position: 3}},
+		Missings: []DBIndexColumnDiffPosting{},
+	}}
+	if !reflect.DeepEqual(result, want) {
+		t.Fatalf("db.IndexColumnDiff failed: actual = %#v, want = %#v", result, want)
+	}
+}
+
+func TestDBQueryLogFlags(t *testing.T) {
+	h := newTestHandler(func(cmd *Command, body string) (string, error) {
+		switch cmd.Name() {
+		case "query_log_flags_get":
+			return `"COMMAND|RESULT_CODE"`, nil
+		default:
+			return `{"previous":"COMMAND|RESULT_CODE","current":"COMMAND"}`, nil
+		}
+	})
+	db := NewDB(h)
+	flags, err := db.QueryLogFlagsGet()
+	if err != nil {
+		t.Fatalf("db.QueryLogFlagsGet failed: %v", err)
+	}
+	if want := []string{"COMMAND", "RESULT_CODE"}; !reflect.DeepEqual(flags, want) {
+		t.Fatalf("db.QueryLogFlagsGet failed: actual = %v, want = %v", flags, want)
+	}
+	result, err := db.QueryLogFlagsRemove([]string{"RESULT_CODE"})
+	if err != nil {
+		t.Fatalf("db.QueryLogFlagsRemove failed: %v", err)
+	}
+	if want := []string{"COMMAND"}; !reflect.DeepEqual(result.Current, want) {
+		t.Fatalf("db.QueryLogFlagsRemove failed: actual = %v, want = %v", result.Current, want)
+	}
+	if actual, want := h.commands[1].String(), "query_log_flags_remove --flags 'RESULT_CODE'"; actual != want {
+		t.Fatalf("db.QueryLogFlagsRemove failed: actual = %s, want = %s", actual, want)
+	}
+}
+
+func TestDBColumnCreateWithOptions(t *testing.T) {
+	h := newTestHandler(nil)
+	db := NewDB(h)
+	options := NewDBColumnCreateOptions()
+	options.Flags = []string{"COMPRESS_ZSTD"}
+	options.Path = "/var/lib/groonga/Tbl.col"
+	if err := db.ColumnCreateWithOptions("Tbl.col", "Text", options); err != nil {
+		t.Fatalf("db.ColumnCreateWithOptions failed: %v", err)
+	}
+	want := "column_create --flags 'COMPRESS_ZSTD|COLUMN_SCALAR' --name 'col' --path '/var/lib/groonga/Tbl.col' --table 'Tbl' --type 'Text'"
+	if actual := h.commands[0].String(); actual != want {
+		t.Fatalf("db.ColumnCreateWithOptions failed: actual = %s, want = %s", actual, want)
+	}
+	if actual := options.Flags; len(actual) != 1 {
+		t.Fatalf("db.ColumnCreateWithOptions modified options: actual = %v", actual)
+	}
+}
+
+func TestDBSelectRowsTime(t *testing.T) {
+	h := newTestHandler(func(cmd *Command, body string) (string, error) {
+		return `[[[3],[["_id","UInt32"],["time","Time"],["times","Time"]],
+[1,1483228800.123456,[1483228800.000001]],
+[2,-1.5,[]],
+[3,0.0,[0.999999,1.0]]]]`, nil
+	})
+	db := NewDB(h)
+	type Row struct {
+		ID    uint32      `grnci:"_id"`
+		Time  time.Time   `grnci:"time"`
+		Times []time.Time `grnci:"times"`
+	}
+	var rows []Row
+	n, err := db.SelectRows("Tbl", &rows, nil)
+	if err != nil {
+		t.Fatalf("db.SelectRows failed: %v", err)
+	}
+	want := []Row{
+		{1, time.Unix(1483228800, 123456000), []time.Time{time.Unix(1483228800, 1000)}},
+		{2, time.Unix(-2, 500000000), []time.Time{}},
+		{3, time.Unix(0, 0), []time.Time{time.Unix(0, 999999000), time.Unix(1, 0)}},
+	}
+	if n != 3 || !reflect.DeepEqual(rows, want) {
+		t.Fatalf("db.SelectRows failed: actual = %#v, want = %#v", rows, want)
+	}
+}
@@ -0,0 +1,207 @@
+// Package dryrun provides a Handler to record commands without a server.
+//
+// Handler records every command with its buffered body and returns canned
+// responses, so that the commands DB would send can be reviewed.
+// The recorded commands can be written as a Groonga command file,
+// which DB.Restore or the groonga command can execute later.
+package dryrun
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/groonga/grnci/v2"
+)
+
+// response is a canned response.
+type response struct {
+	*bytes.Reader
+	start time.Time
+}
+
+// newResponse returns a new response.
+func newResponse(body string) *response {
+	return &response{
+		Reader: bytes.NewReader([]byte(body)),
+		start:  time.Now(),
+	}
+}
+
+// Start returns the time when the response is created.
+func (r *response) Start() time.Time {
+	return r.start
+}
+
+// Elapsed returns zero.
+func (r *response) Elapsed() time.Duration {
+	return 0
+}
+
+// Close does nothing.
+func (r *response) Close() error {
+	return nil
+}
+
+// Err returns nil.
+func (r *response) Err() error {
+	return nil
+}
+
+// Entry is a recorded command.
+type Entry struct {
+	Command *grnci.Command // Command
+	Body    []byte         // Buffered body (nil if the command has no body)
+}
+
+// HandlerOptions stores options for Handler.
+type HandlerOptions struct {
+	Default  string            // Response for commands without fixtures
+	Fixtures map[string]string // Responses by command name
+	Count    bool              // Whether or not load returns the number of records in the body
+}
+
+// NewHandlerOptions returns the default HandlerOptions.
+func NewHandlerOptions() *HandlerOptions {
+	return &HandlerOptions{
+		Default: "true",
+		Count:   true,
+	}
+}
+
+// Handler is a Handler to record commands.
+type Handler struct {
+	options *HandlerOptions
+	entries []*Entry
+	mutex   sync.Mutex
+}
+
+// NewHandler returns a new Handler.
+func NewHandler(options *HandlerOptions) *Handler {
+	if options == nil {
+		options = NewHandlerOptions()
+	}
+	return &Handler{
+		options: options,
+	}
+}
+
+// Exec parses cmd, records the parsed command and returns a canned response.
+func (h *Handler) Exec(cmd string, body io.Reader) (grnci.Response, error) {
+	command, err := grnci.ParseCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	command.SetBody(body)
+	return h.Query(command)
+}
+
+// Invoke assembles name and params into a command,
+// records the command and returns a canned response.
+func (h *Handler) Invoke(name string, params map[string]interface{}, body io.Reader) (grnci.Response, error) {
+	cmd, err := grnci.NewCommand(name, params)
+	if err != nil {
+		return nil, err
+	}
+	cmd.SetBody(body)
+	return h.Query(cmd)
+}
+
+// Query records cmd and returns a canned response.
+func (h *Handler) Query(cmd *grnci.Command) (grnci.Response, error) {
+	if err := cmd.Check(); err != nil {
+		return nil, err
+	}
+	entry := &Entry{Command: cmd}
+	if cmd.Body() != nil {
+		body, err := ioutil.ReadAll(cmd.Body())
+		if err != nil {
+			return nil, grnci.NewError(grnci.InputError, "ioutil.ReadAll failed.", map[string]interface{}{
+				"name":  cmd.Name(),
+				"error": err.Error(),
+			})
+		}
+		entry.Body = body
+		cmd.SetBody(bytes.NewReader(body))
+	}
+	h.mutex.Lock()
+	h.entries = append(h.entries, entry)
+	h.mutex.Unlock()
+	return newResponse(h.respond(entry)), nil
+}
+
+// respond returns the response body for entry.
+func (h *Handler) respond(entry *Entry) string {
+	cmd := entry.Command
+	if body, ok := h.options.Fixtures[cmd.Name()]; ok {
+		return body
+	}
+	if cmd.Name() == "load" && h.options.Count {
+		values := entry.Body
+		if v, ok := cmd.Params()["values"]; ok {
+			values = []byte(v)
+		}
+		_, hasColumns := cmd.Params()["columns"]
+		return strconv.Itoa(countRecords(values, hasColumns))
+	}
+	return h.options.Default
+}
+
+// countRecords returns the number of records in values of load.
+// If hasColumns is false, the leading array of column names is not counted.
+func countRecords(values []byte, hasColumns bool) int {
+	var records []json.RawMessage
+	if err := json.Unmarshal(values, &records); err != nil {
+		return 0
+	}
+	n := len(records)
+	if n != 0 && !hasColumns && bytes.HasPrefix(bytes.TrimSpace(records[0]), []byte("[")) {
+		n--
+	}
+	return n
+}
+
+// Close does nothing.
+func (h *Handler) Close() error {
+	return nil
+}
+
+// Entries returns the recorded commands.
+func (h *Handler) Entries() []*Entry {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	entries := make([]*Entry, len(h.entries))
+	copy(entries, h.entries)
+	return entries
+}
+
+// Reset discards the recorded commands.
+func (h *Handler) Reset() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.entries = nil
+}
+
+// WriteTo writes the recorded commands as a Groonga command file.
+func (h *Handler) WriteTo(w io.Writer) (int64, error) {
+	var buf []byte
+	for _, entry := range h.Entries() {
+		buf = append(buf, entry.Command.String()...)
+		buf = append(buf, '\n')
+		if entry.Body != nil {
+			buf = append(buf, bytes.TrimRight(entry.Body, "\r\n")...)
+			buf = append(buf, '\n')
+		}
+	}
+	n, err := w.Write(buf)
+	if err != nil {
+		return int64(n), grnci.NewError(grnci.OutputError, "io.Writer.Write failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	return int64(n), nil
+}
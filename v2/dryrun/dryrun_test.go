@@ -0,0 +1,77 @@
+package dryrun
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/groonga/grnci/v2"
+)
+
+type testRow struct {
+	Key   string `grnci:"_key"`
+	Value int    `grnci:"value"`
+}
+
+func TestHandler(t *testing.T) {
+	options := NewHandlerOptions()
+	options.Fixtures = map[string]string{
+		"table_list": `[[["id","UInt32"],["name","ShortText"]],[256,"Tbl"]]`,
+	}
+	h := NewHandler(options)
+	db := grnci.NewDB(h)
+	if err := db.TableCreate("Tbl", nil); err != nil {
+		t.Fatalf("db.TableCreate failed: %v", err)
+	}
+	n, err := db.LoadRows("Tbl", []testRow{{"a", 1}, {"b", 2}}, nil)
+	if err != nil {
+		t.Fatalf("db.LoadRows failed: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("db.LoadRows failed: actual = %d, want = %d", n, 2)
+	}
+	tables, err := db.TableList()
+	if err != nil {
+		t.Fatalf("db.TableList failed: %v", err)
+	}
+	if len(tables) != 1 || tables[0].Name != "Tbl" {
+		t.Fatalf("db.TableList failed: actual = %#v", tables)
+	}
+	var buf bytes.Buffer
+	if _, err := h.WriteTo(&buf); err != nil {
+		t.Fatalf("h.WriteTo failed: %v", err)
+	}
+	want := "table_create --flags 'TABLE_NO_KEY' --name 'Tbl'\n" +
+		"load --columns '_key,value' --table 'Tbl'\n" +
+		`[["a",1],["b",2]]` + "\n" +
+		"table_list\n"
+	if actual := buf.String(); actual != want {
+		t.Fatalf("h.WriteTo failed: actual = %s, want = %s", actual, want)
+	}
+
+	// The command file is replayable.
+	replay := NewHandler(nil)
+	if n, err := grnci.NewDB(replay).Restore(&buf, nil, true); err != nil || n != 3 {
+		t.Fatalf("db.Restore failed: n = %d, err = %v", n, err)
+	}
+	if entries := replay.Entries(); string(entries[1].Body) != `[["a",1],["b",2]]`+"\n" {
+		t.Fatalf("db.Restore failed: body = %s", entries[1].Body)
+	}
+}
+
+func TestCountRecords(t *testing.T) {
+	data := []struct {
+		values     string
+		hasColumns bool
+		want       int
+	}{
+		{`[["_key"],["a"],["b"]]`, false, 2},
+		{`[["a"],["b"]]`, true, 2},
+		{`[{"_key":"a"}]`, false, 1},
+		{`broken`, false, 0},
+	}
+	for _, d := range data {
+		if actual := countRecords([]byte(d.values), d.hasColumns); actual != d.want {
+			t.Fatalf("countRecords failed: values = %s, actual = %d, want = %d", d.values, actual, d.want)
+		}
+	}
+}
@@ -21,6 +21,7 @@ const (
 	HTTPError
 	GroongaError
 	UnexpectedError
+	BlockedError
 )
 
 // Name returns the name of the ErrorCode,
@@ -50,6 +51,8 @@ func (ec ErrorCode) Name() string {
 		return "GroongaError"
 	case UnexpectedError:
 		return "UnexpectedError"
+	case BlockedError:
+		return "BlockedError"
 
 	case 0:
 		return "GRN_SUCCESS"
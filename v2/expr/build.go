@@ -0,0 +1,150 @@
+package expr
+
+// Col returns a column reference.
+func Col(name string) *Column {
+	return &Column{Name: name}
+}
+
+// Val returns a literal.
+func Val(v interface{}) *Value {
+	return &Value{Value: v}
+}
+
+// toExpr returns v if v is an Expr, otherwise a literal.
+func toExpr(v interface{}) Expr {
+	if e, ok := v.(Expr); ok {
+		return e
+	}
+	return Val(v)
+}
+
+// Op returns a binary operation.
+// If x or y is not an Expr, it is treated as a literal.
+func Op(x interface{}, op string, y interface{}) *Binary {
+	return &Binary{Op: op, X: toExpr(x), Y: toExpr(y)}
+}
+
+// Equal returns "col == v".
+func Equal(col string, v interface{}) Expr {
+	return Op(Col(col), "==", v)
+}
+
+// NotEqual returns "col != v".
+func NotEqual(col string, v interface{}) Expr {
+	return Op(Col(col), "!=", v)
+}
+
+// Less returns "col < v".
+func Less(col string, v interface{}) Expr {
+	return Op(Col(col), "<", v)
+}
+
+// LessEqual returns "col <= v".
+func LessEqual(col string, v interface{}) Expr {
+	return Op(Col(col), "<=", v)
+}
+
+// Greater returns "col > v".
+func Greater(col string, v interface{}) Expr {
+	return Op(Col(col), ">", v)
+}
+
+// GreaterEqual returns "col >= v".
+func GreaterEqual(col string, v interface{}) Expr {
+	return Op(Col(col), ">=", v)
+}
+
+// Match returns "col @ v" (full text search).
+func Match(col string, v interface{}) Expr {
+	return Op(Col(col), "@", v)
+}
+
+// Prefix returns "col @^ v" (prefix search).
+func Prefix(col string, v interface{}) Expr {
+	return Op(Col(col), "@^", v)
+}
+
+// Suffix returns "col @$ v" (suffix search).
+func Suffix(col string, v interface{}) Expr {
+	return Op(Col(col), "@$", v)
+}
+
+// Regexp returns "col @~ v" (regular expression search).
+func Regexp(col string, v interface{}) Expr {
+	return Op(Col(col), "@~", v)
+}
+
+// join joins es with a logical operator.
+// If es is empty, empty is returned.
+func join(op string, empty bool, es []Expr) Expr {
+	if len(es) == 0 {
+		return Val(empty)
+	}
+	e := es[0]
+	for _, y := range es[1:] {
+		e = &Binary{Op: op, X: e, Y: y}
+	}
+	return e
+}
+
+// And returns "e1 && e2 && ...".
+// If es is empty, And returns true.
+func And(es ...Expr) Expr {
+	return join("&&", true, es)
+}
+
+// Or returns "e1 || e2 || ...".
+// If es is empty, Or returns false.
+func Or(es ...Expr) Expr {
+	return join("||", false, es)
+}
+
+// AndNot returns "x &! y1 &! y2 &! ...".
+func AndNot(x Expr, ys ...Expr) Expr {
+	return join("&!", true, append([]Expr{x}, ys...))
+}
+
+// Not returns "!x".
+func Not(x Expr) Expr {
+	return &Unary{Op: "!", X: x}
+}
+
+// Func returns a function call.
+// Arguments that are not an Expr are treated as literals.
+func Func(name string, args ...interface{}) *Call {
+	c := &Call{Func: name}
+	for _, arg := range args {
+		c.Args = append(c.Args, toExpr(arg))
+	}
+	return c
+}
+
+// AllRecords returns "all_records()".
+func AllRecords() Expr {
+	return Func("all_records")
+}
+
+// InValues returns "in_values(col, v1, v2, ...)".
+func InValues(col string, values ...interface{}) Expr {
+	return Func("in_values", append([]interface{}{Col(col)}, values...)...)
+}
+
+// border returns the border of between.
+func border(inclusive bool) string {
+	if inclusive {
+		return "include"
+	}
+	return "exclude"
+}
+
+// Between returns "between(col, min, min_border, max, max_border)".
+func Between(col string, min interface{}, minInclusive bool, max interface{}, maxInclusive bool) Expr {
+	return Func("between", Col(col), min, border(minInclusive), max, border(maxInclusive))
+}
+
+// GeoInCircle returns "geo_in_circle(col, center, radius)".
+// center is a grnci.Geo or a string such as "35.6813x139.7660".
+// radius is the distance in meters.
+func GeoInCircle(col string, center interface{}, radius int) Expr {
+	return Func("geo_in_circle", Col(col), center, radius)
+}
@@ -0,0 +1,414 @@
+// Package expr provides Groonga script syntax expressions.
+//
+// An expression is a tree of Column, Value, Unary, Binary, Call and Array nodes.
+// String renders a tree in script syntax and the result is available as
+// --filter of select, delete, logical_count and drilldowns.
+//
+//	options := grnci.NewDBSelectOptions()
+//	options.Filter = expr.And(
+//		expr.Match("title", userInput),
+//		expr.Between("price", 100, true, 200, false),
+//	).String()
+//
+// String literals are escaped in the same way as grnci.AppendJSONString.
+// Column names and function names are not escaped, so use Format to
+// validate them if they come from untrusted input.
+//
+// Parse parses a filter into a tree, Validate checks column references
+// against a schema and Rewrite transforms a tree. For example, a tenant
+// condition is injected into an untrusted filter as follows:
+//
+//	e, err := expr.Parse(filter)
+//	if err != nil {
+//		return err
+//	}
+//	if err := expr.Validate(e, schema, "Posts"); err != nil {
+//		return err
+//	}
+//	options.Filter = expr.And(expr.Equal("tenant_id", tenantID), e).String()
+package expr
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/groonga/grnci/v2"
+)
+
+// Operator precedences.
+const (
+	precOr = iota + 1
+	precAnd
+	precBitOr
+	precBitXor
+	precBitAnd
+	precEquality
+	precRelational
+	precShift
+	precAdditive
+	precMultiplicative
+	precUnary
+	precPrimary
+)
+
+// binaryOps maps binary operators to their precedences.
+var binaryOps = map[string]int{
+	"||":  precOr,
+	"&&":  precAnd,
+	"&!":  precAnd,
+	"|":   precBitOr,
+	"^":   precBitXor,
+	"&":   precBitAnd,
+	"==":  precEquality,
+	"!=":  precEquality,
+	"<":   precRelational,
+	"<=":  precRelational,
+	">":   precRelational,
+	">=":  precRelational,
+	"@":   precRelational,
+	"@^":  precRelational,
+	"@$":  precRelational,
+	"@~":  precRelational,
+	"*N":  precRelational,
+	"*S":  precRelational,
+	"*T":  precRelational,
+	"<<":  precShift,
+	">>":  precShift,
+	">>>": precShift,
+	"+":   precAdditive,
+	"-":   precAdditive,
+	"*":   precMultiplicative,
+	"/":   precMultiplicative,
+	"%":   precMultiplicative,
+}
+
+// unaryOps is a set of the unary operators.
+var unaryOps = map[string]bool{
+	"!": true,
+	"-": true,
+	"+": true,
+	"~": true,
+}
+
+// Expr is a node of an expression.
+type Expr interface {
+	// String returns the expression in script syntax.
+	String() string
+
+	// appendTo appends the expression to buf and returns the extended buffer.
+	appendTo(buf []byte) []byte
+	// precedence returns the precedence of the expression.
+	precedence() int
+	// check checks if the expression is valid.
+	check() error
+}
+
+// Format validates e and returns the expression in script syntax.
+func Format(e Expr) (string, error) {
+	if e == nil {
+		return "", grnci.NewError(grnci.CommandError, "The expression must not be nil.", nil)
+	}
+	if err := e.check(); err != nil {
+		return "", err
+	}
+	return e.String(), nil
+}
+
+// Column is a column reference, such as _key, title and user.name.
+type Column struct {
+	Name string
+}
+
+// String returns the column name.
+func (c *Column) String() string {
+	return c.Name
+}
+
+func (c *Column) appendTo(buf []byte) []byte {
+	return append(buf, c.Name...)
+}
+
+func (c *Column) precedence() int {
+	return precPrimary
+}
+
+// checkName checks if s is valid as a column name or a function name.
+func checkName(kind, s string) error {
+	if s == "" {
+		return grnci.NewError(grnci.CommandError, "The "+kind+" name must not be empty.", nil)
+	}
+	if s[0] >= '0' && s[0] <= '9' {
+		return grnci.NewError(grnci.CommandError, "The "+kind+" name must not start with a digit.", map[string]interface{}{
+			"name": s,
+		})
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= '0' && c <= '9':
+		case c >= 'A' && c <= 'Z':
+		case c >= 'a' && c <= 'z':
+		case c == '_', c == '.':
+		default:
+			return grnci.NewError(grnci.CommandError, "The "+kind+" name must consist of [0-9A-Za-z_.].", map[string]interface{}{
+				"name": s,
+			})
+		}
+	}
+	return nil
+}
+
+func (c *Column) check() error {
+	return checkName("column", c.Name)
+}
+
+// Number is a numeric literal kept as written, such as 1, 1.0 and 1e3.
+// A negative number is represented as a Unary with "-".
+type Number string
+
+// Value is a literal, such as a string, a number, a boolean, time.Time or grnci.Geo.
+// Slices and arrays are rendered as vector literals.
+type Value struct {
+	Value interface{}
+}
+
+// String returns the literal in script syntax.
+func (v *Value) String() string {
+	return string(v.appendTo(nil))
+}
+
+func (v *Value) appendTo(buf []byte) []byte {
+	if n, ok := v.Value.(Number); ok {
+		return append(buf, n...)
+	}
+	return grnci.AppendJSONValue(buf, reflect.ValueOf(v.Value))
+}
+
+func (v *Value) precedence() int {
+	return precPrimary
+}
+
+// checkValue checks if v is available as a literal.
+func checkValue(v reflect.Value) error {
+	if !v.IsValid() {
+		return nil
+	}
+	if n, ok := v.Interface().(Number); ok {
+		if _, err := strconv.ParseFloat(string(n), 64); err != nil || n == "" || n[0] == '-' || n[0] == '+' {
+			return grnci.NewError(grnci.TypeError, "The number is invalid.", map[string]interface{}{
+				"value": string(n),
+			})
+		}
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Invalid, reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return nil
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return grnci.NewError(grnci.TypeError, "The value must be a finite number.", map[string]interface{}{
+				"value": f,
+			})
+		}
+		return nil
+	case reflect.Struct:
+		switch v.Interface().(type) {
+		case time.Time, grnci.Geo:
+			return nil
+		}
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return checkValue(v.Elem())
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := checkValue(v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return grnci.NewError(grnci.TypeError, "The type is not supported as a value.", map[string]interface{}{
+		"type": v.Type().String(),
+	})
+}
+
+func (v *Value) check() error {
+	return checkValue(reflect.ValueOf(v.Value))
+}
+
+// Unary is a unary operation, such as !x and -x.
+type Unary struct {
+	Op string
+	X  Expr
+}
+
+// String returns the operation in script syntax.
+func (u *Unary) String() string {
+	return string(u.appendTo(nil))
+}
+
+func (u *Unary) appendTo(buf []byte) []byte {
+	buf = append(buf, u.Op...)
+	return appendOperand(buf, u.X, precUnary+1)
+}
+
+func (u *Unary) precedence() int {
+	return precUnary
+}
+
+func (u *Unary) check() error {
+	if !unaryOps[u.Op] {
+		return grnci.NewError(grnci.CommandError, "The operator is not supported.", map[string]interface{}{
+			"op": u.Op,
+		})
+	}
+	if u.X == nil {
+		return grnci.NewError(grnci.CommandError, "The operand must not be nil.", map[string]interface{}{
+			"op": u.Op,
+		})
+	}
+	return u.X.check()
+}
+
+// Binary is a binary operation, such as x == y and x && y.
+type Binary struct {
+	Op string
+	X  Expr
+	Y  Expr
+}
+
+// String returns the operation in script syntax.
+func (b *Binary) String() string {
+	return string(b.appendTo(nil))
+}
+
+func (b *Binary) appendTo(buf []byte) []byte {
+	prec := b.precedence()
+	buf = appendOperand(buf, b.X, prec)
+	buf = append(buf, ' ')
+	buf = append(buf, b.Op...)
+	buf = append(buf, ' ')
+	// Binary operators are left-associative.
+	return appendOperand(buf, b.Y, prec+1)
+}
+
+func (b *Binary) precedence() int {
+	return binaryOps[b.Op]
+}
+
+func (b *Binary) check() error {
+	if _, ok := binaryOps[b.Op]; !ok {
+		return grnci.NewError(grnci.CommandError, "The operator is not supported.", map[string]interface{}{
+			"op": b.Op,
+		})
+	}
+	if b.X == nil || b.Y == nil {
+		return grnci.NewError(grnci.CommandError, "The operand must not be nil.", map[string]interface{}{
+			"op": b.Op,
+		})
+	}
+	if err := b.X.check(); err != nil {
+		return err
+	}
+	return b.Y.check()
+}
+
+// Call is a function call, such as in_values(tag, "a", "b").
+type Call struct {
+	Func string
+	Args []Expr
+}
+
+// String returns the function call in script syntax.
+func (c *Call) String() string {
+	return string(c.appendTo(nil))
+}
+
+func (c *Call) appendTo(buf []byte) []byte {
+	buf = append(buf, c.Func...)
+	buf = append(buf, '(')
+	for i, arg := range c.Args {
+		if i != 0 {
+			buf = append(buf, ", "...)
+		}
+		buf = appendOperand(buf, arg, 0)
+	}
+	return append(buf, ')')
+}
+
+func (c *Call) precedence() int {
+	return precPrimary
+}
+
+func (c *Call) check() error {
+	if err := checkName("function", c.Func); err != nil {
+		return err
+	}
+	for _, arg := range c.Args {
+		if arg == nil {
+			return grnci.NewError(grnci.CommandError, "The argument must not be nil.", map[string]interface{}{
+				"func": c.Func,
+			})
+		}
+		if err := arg.check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Array is a vector literal, such as [1, 2, 3].
+type Array struct {
+	Elems []Expr
+}
+
+// String returns the vector literal in script syntax.
+func (a *Array) String() string {
+	return string(a.appendTo(nil))
+}
+
+func (a *Array) appendTo(buf []byte) []byte {
+	buf = append(buf, '[')
+	for i, elem := range a.Elems {
+		if i != 0 {
+			buf = append(buf, ", "...)
+		}
+		buf = appendOperand(buf, elem, 0)
+	}
+	return append(buf, ']')
+}
+
+func (a *Array) precedence() int {
+	return precPrimary
+}
+
+func (a *Array) check() error {
+	for _, elem := range a.Elems {
+		if elem == nil {
+			return grnci.NewError(grnci.CommandError, "The element must not be nil.", nil)
+		}
+		if err := elem.check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendOperand appends e to buf and encloses it in parentheses
+// if its precedence is lower than prec.
+func appendOperand(buf []byte, e Expr, prec int) []byte {
+	if e == nil {
+		return append(buf, "null"...)
+	}
+	if e.precedence() < prec {
+		buf = append(buf, '(')
+		buf = e.appendTo(buf)
+		return append(buf, ')')
+	}
+	return e.appendTo(buf)
+}
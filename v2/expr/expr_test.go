@@ -0,0 +1,57 @@
+package expr
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/groonga/grnci/v2"
+)
+
+func TestExprString(t *testing.T) {
+	data := map[string]Expr{
+		`title == "Groonga"`:                        Equal("title", "Groonga"),
+		`title == "say \"hi\"\\\n"`:                 Equal("title", "say \"hi\"\\\n"),
+		`price >= 100 && price < 200`:               And(GreaterEqual("price", 100), Less("price", 200)),
+		`body @ "x" || body @^ "y" || body @~ "^z"`: Or(Match("body", "x"), Prefix("body", "y"), Regexp("body", "^z")),
+		`(a == 1 || b == 2) && c != 3`:              And(Or(Equal("a", 1), Equal("b", 2)), NotEqual("c", 3)),
+		`a == 1 || b == 2 && c == 3`:                Or(Equal("a", 1), And(Equal("b", 2), Equal("c", 3))),
+		`all_records() &! tag == "x" &! tag == "y"`: AndNot(AllRecords(), Equal("tag", "x"), Equal("tag", "y")),
+		`!(a == 1)`:                Not(Equal("a", 1)),
+		`in_values(tag, "a", "b")`: InValues("tag", "a", "b"),
+		`between(price, 1, "include", 2, "exclude")`:          Between("price", 1, true, 2, false),
+		`geo_in_circle(location, "35681396,139766049", 1000)`: GeoInCircle("location", grnci.Geo{Lat: 35681396, Long: 139766049}, 1000),
+		`updated_at > 1234567890.500000`:                      Greater("updated_at", time.Unix(1234567890, 500000000)),
+		`a - (b - c)`:                                         Op(Col("a"), "-", Op(Col("b"), "-", Col("c"))),
+		`a - b - c`:                                           Op(Op(Col("a"), "-", Col("b")), "-", Col("c")),
+		`true`:                                                And(),
+		`false`:                                               Or(),
+	}
+	for want, e := range data {
+		if actual := e.String(); actual != want {
+			t.Fatalf("e.String failed: actual = %s, want = %s", actual, want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if actual, err := Format(Equal("user.name", "x")); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	} else if want := `user.name == "x"`; actual != want {
+		t.Fatalf("Format failed: actual = %s, want = %s", actual, want)
+	}
+	invalid := []Expr{
+		Equal("title || true", "x"),
+		Equal("", "x"),
+		Equal("price", math.NaN()),
+		Equal("price", struct{}{}),
+		Func("f(x)"),
+		Op(Col("a"), "=", 1),
+		Not(nil),
+	}
+	for _, e := range invalid {
+		if _, err := Format(e); err == nil {
+			t.Fatalf("Format succeeded: %s", e)
+		}
+	}
+}
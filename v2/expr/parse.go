@@ -0,0 +1,344 @@
+package expr
+
+import (
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/groonga/grnci/v2"
+)
+
+// tokenType is the type of a token.
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOp
+)
+
+// token is a token of script syntax.
+type token struct {
+	typ tokenType
+	s   string // Identifier, number, operator or unescaped string
+	pos int    // Byte offset in the source
+}
+
+// parseOps is the list of operators in longest-match order.
+var parseOps = []string{
+	">>>",
+	"||", "&&", "&!", "==", "!=", "<=", ">=", "<<", ">>", "@^", "@$", "@~",
+	"<", ">", "@", "|", "^", "&", "+", "-", "*", "/", "%", "!", "~",
+	"(", ")", ",", "[", "]",
+}
+
+// isIdentStart returns whether or not c can start an identifier.
+func isIdentStart(c byte) bool {
+	return (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || c == '_'
+}
+
+// isIdentChar returns whether or not c can be a part of an identifier.
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9') || c == '.'
+}
+
+// parser is a parser of script syntax.
+type parser struct {
+	src string
+	pos int
+	tok token
+}
+
+// error returns an error at the current token.
+func (p *parser) error(msg string) error {
+	return grnci.NewError(grnci.InputError, msg, map[string]interface{}{
+		"expr": p.src,
+		"pos":  p.tok.pos,
+	})
+}
+
+// next reads the next token.
+func (p *parser) next() error {
+	s := p.src
+	for p.pos < len(s) {
+		switch s[p.pos] {
+		case ' ', '\t', '\n', '\r', '\v', '\f':
+			p.pos++
+			continue
+		}
+		break
+	}
+	start := p.pos
+	if p.pos == len(s) {
+		p.tok = token{typ: tokenEOF, pos: start}
+		return nil
+	}
+	c := s[p.pos]
+	switch {
+	case isIdentStart(c):
+		for p.pos < len(s) && isIdentChar(s[p.pos]) {
+			p.pos++
+		}
+		p.tok = token{typ: tokenIdent, s: s[start:p.pos], pos: start}
+		return nil
+	case c >= '0' && c <= '9':
+		for p.pos < len(s) && (s[p.pos] >= '0' && s[p.pos] <= '9' || s[p.pos] == '.') {
+			p.pos++
+		}
+		if p.pos < len(s) && (s[p.pos] == 'e' || s[p.pos] == 'E') {
+			p.pos++
+			if p.pos < len(s) && (s[p.pos] == '+' || s[p.pos] == '-') {
+				p.pos++
+			}
+			for p.pos < len(s) && s[p.pos] >= '0' && s[p.pos] <= '9' {
+				p.pos++
+			}
+		}
+		p.tok = token{typ: tokenNumber, s: s[start:p.pos], pos: start}
+		if _, err := strconv.ParseFloat(p.tok.s, 64); err != nil {
+			return p.error("The number is invalid.")
+		}
+		return nil
+	case c == '"' || c == '\'':
+		str, err := p.readString(c)
+		if err != nil {
+			return err
+		}
+		p.tok = token{typ: tokenString, s: str, pos: start}
+		return nil
+	}
+	for _, op := range parseOps {
+		if len(s)-p.pos >= len(op) && s[p.pos:p.pos+len(op)] == op {
+			p.pos += len(op)
+			p.tok = token{typ: tokenOp, s: op, pos: start}
+			return nil
+		}
+	}
+	p.tok = token{pos: start}
+	return p.error("The character is not supported.")
+}
+
+// readString reads a string literal quoted by q.
+// Escape sequences are the same as grnci.AppendJSONString, and
+// '\' followed by another character means the character itself.
+func (p *parser) readString(q byte) (string, error) {
+	s := p.src
+	start := p.pos
+	p.pos++
+	var buf []byte
+	for p.pos < len(s) {
+		c := s[p.pos]
+		p.pos++
+		switch c {
+		case q:
+			return string(buf), nil
+		case '\\':
+			if p.pos == len(s) {
+				break
+			}
+			c = s[p.pos]
+			p.pos++
+			switch c {
+			case 'b':
+				buf = append(buf, '\b')
+			case 't':
+				buf = append(buf, '\t')
+			case 'n':
+				buf = append(buf, '\n')
+			case 'f':
+				buf = append(buf, '\f')
+			case 'r':
+				buf = append(buf, '\r')
+			case 'u':
+				if len(s)-p.pos < 4 {
+					p.tok = token{pos: p.pos - 2}
+					return "", p.error("The escape sequence is invalid.")
+				}
+				r, err := strconv.ParseUint(s[p.pos:p.pos+4], 16, 16)
+				if err != nil {
+					p.tok = token{pos: p.pos - 2}
+					return "", p.error("The escape sequence is invalid.")
+				}
+				p.pos += 4
+				var tmp [utf8.UTFMax]byte
+				buf = append(buf, tmp[:utf8.EncodeRune(tmp[:], rune(r))]...)
+			default:
+				buf = append(buf, c)
+			}
+		default:
+			buf = append(buf, c)
+		}
+	}
+	p.tok = token{pos: start}
+	return "", p.error("The string is not terminated.")
+}
+
+// isOp returns whether or not the current token is op.
+func (p *parser) isOp(op string) bool {
+	return p.tok.typ == tokenOp && p.tok.s == op
+}
+
+// expect reads op or returns an error.
+func (p *parser) expect(op string) error {
+	if !p.isOp(op) {
+		return p.error("'" + op + "' is expected.")
+	}
+	return p.next()
+}
+
+// parseBinary parses binary operations whose precedences are prec or higher.
+func (p *parser) parseBinary(prec int) (Expr, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.typ == tokenOp {
+		op, opPos := p.tok.s, p.tok.pos
+		opPrec, ok := binaryOps[op]
+		if !ok || opPrec < prec {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		// "*N", "*S" and "*T" are single operators.
+		if op == "*" && p.tok.typ == tokenIdent && p.tok.pos == opPos+1 {
+			switch p.tok.s {
+			case "N", "S", "T":
+				op += p.tok.s
+				opPrec = binaryOps[op]
+				if err := p.next(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		y, err := p.parseBinary(opPrec + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &Binary{Op: op, X: x, Y: y}
+	}
+	return x, nil
+}
+
+// parseUnary parses a unary operation.
+func (p *parser) parseUnary() (Expr, error) {
+	if p.tok.typ == tokenOp && unaryOps[p.tok.s] {
+		op := p.tok.s
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{Op: op, X: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parseList parses a comma-separated list terminated by end.
+func (p *parser) parseList(end string) ([]Expr, error) {
+	var list []Expr
+	if p.isOp(end) {
+		return list, p.next()
+	}
+	for {
+		e, err := p.parseBinary(precOr)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+		if p.isOp(end) {
+			return list, p.next()
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parsePrimary parses a primary expression.
+func (p *parser) parsePrimary() (Expr, error) {
+	tok := p.tok
+	switch tok.typ {
+	case tokenIdent:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		switch tok.s {
+		case "true":
+			return Val(true), nil
+		case "false":
+			return Val(false), nil
+		case "null":
+			return Val(nil), nil
+		}
+		if p.isOp("(") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			args, err := p.parseList(")")
+			if err != nil {
+				return nil, err
+			}
+			return &Call{Func: tok.s, Args: args}, nil
+		}
+		return Col(tok.s), nil
+	case tokenNumber:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		return Val(Number(tok.s)), nil
+	case tokenString:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		return Val(tok.s), nil
+	case tokenOp:
+		switch tok.s {
+		case "(":
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			e, err := p.parseBinary(precOr)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return e, nil
+		case "[":
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			elems, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			return &Array{Elems: elems}, nil
+		}
+	case tokenEOF:
+		return nil, p.error("The expression is incomplete.")
+	}
+	return nil, p.error("The token is unexpected.")
+}
+
+// Parse parses s in script syntax and returns the expression.
+// Assignments, the conditional operator and object literals are not supported.
+func Parse(s string) (Expr, error) {
+	p := &parser{src: s}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	e, err := p.parseBinary(precOr)
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.typ != tokenEOF {
+		return nil, p.error("The token is unexpected.")
+	}
+	return e, nil
+}
@@ -0,0 +1,121 @@
+package expr
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/groonga/grnci/v2"
+)
+
+func TestParse(t *testing.T) {
+	data := map[string]string{
+		`title == "Groonga"`:                           `title == "Groonga"`,
+		`(a == 1 || b == 2) && c != 3`:                 `(a == 1 || b == 2) && c != 3`,
+		`((a == 1)) || (b == 2 && c == 3)`:             `a == 1 || b == 2 && c == 3`,
+		`a - (b - c)`:                                  `a - (b - c)`,
+		`-1.0 + 2e3 * x`:                               `-1.0 + 2e3 * x`,
+		`!(a @ 'x\'y') &! user.name @^ "a\nあ"`:         `!(a @ "x'y") &! user.name @^ "a\nあ"`,
+		`in_values(tag, "a", "b") && all_records()`:    `in_values(tag, "a", "b") && all_records()`,
+		`tags == ["a","b"] || flag == true || x==null`: `tags == ["a", "b"] || flag == true || x == null`,
+		`body *N "a b" && x * N > 1`:                   `body *N "a b" && x * N > 1`,
+	}
+	for src, want := range data {
+		e, err := Parse(src)
+		if err != nil {
+			t.Fatalf("Parse failed: src = %s, err = %v", src, err)
+		}
+		if actual := e.String(); actual != want {
+			t.Fatalf("Parse failed: actual = %s, want = %s", actual, want)
+		}
+		if _, err := Parse(e.String()); err != nil {
+			t.Fatalf("Parse failed: src = %s, err = %v", e.String(), err)
+		}
+	}
+	invalid := []string{
+		``,
+		`a ==`,
+		`(a == 1`,
+		`"abc`,
+		`a = 1`,
+		`a == 1 b`,
+		`f(a,)`,
+		`a ? b : c`,
+	}
+	for _, src := range invalid {
+		if _, err := Parse(src); err == nil {
+			t.Fatalf("Parse succeeded: src = %s", src)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	schema := &grnci.DBSchema{
+		Tables: map[string]grnci.DBSchemaTable{
+			"Posts": {
+				Name: "Posts",
+				Columns: map[string]grnci.DBSchemaColumn{
+					"title": {Name: "title", ValueType: grnci.DBSchemaValueType{Name: "ShortText"}},
+					"user":  {Name: "user", ValueType: grnci.DBSchemaValueType{Name: "Users"}},
+				},
+			},
+			"Users": {
+				Name:    "Users",
+				KeyType: &grnci.DBSchemaKeyType{Name: "ShortText"},
+				Columns: map[string]grnci.DBSchemaColumn{
+					"name": {Name: "name", ValueType: grnci.DBSchemaValueType{Name: "ShortText"}},
+				},
+			},
+		},
+	}
+	valid := []string{
+		`title @ "x" && user.name == "y" && user._key == "z" && _id > 0`,
+		`in_values(user, "a", "b")`,
+	}
+	for _, src := range valid {
+		e, err := Parse(src)
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		if err := Validate(e, schema, "Posts"); err != nil {
+			t.Fatalf("Validate failed: src = %s, err = %v", src, err)
+		}
+	}
+	invalid := []string{
+		`body @ "x"`,
+		`user.email == "x"`,
+		`title.name == "x"`,
+		`_key == "x"`,
+	}
+	for _, src := range invalid {
+		e, err := Parse(src)
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		if err := Validate(e, schema, "Posts"); err == nil {
+			t.Fatalf("Validate succeeded: src = %s", src)
+		}
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	e, err := Parse(`title @ "x" || body @ "x"`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	e = And(Equal("tenant_id", 10), e)
+	if actual, want := e.String(), `tenant_id == 10 && (title @ "x" || body @ "x")`; actual != want {
+		t.Fatalf("And failed: actual = %s, want = %s", actual, want)
+	}
+	renamed := Rewrite(e, func(e Expr) Expr {
+		if c, ok := e.(*Column); ok && c.Name == "body" {
+			return Col("content")
+		}
+		return e
+	})
+	if actual, want := renamed.String(), `tenant_id == 10 && (title @ "x" || content @ "x")`; actual != want {
+		t.Fatalf("Rewrite failed: actual = %s, want = %s", actual, want)
+	}
+	if actual, want := Columns(e), []string{"tenant_id", "title", "body"}; !reflect.DeepEqual(actual, want) {
+		t.Fatalf("Columns failed: actual = %v, want = %v", actual, want)
+	}
+}
@@ -0,0 +1,151 @@
+package expr
+
+import (
+	"strings"
+
+	"github.com/groonga/grnci/v2"
+)
+
+// Walk traverses e in depth-first order.
+// If f returns false, the children of the node are skipped.
+func Walk(e Expr, f func(Expr) bool) {
+	if e == nil || !f(e) {
+		return
+	}
+	switch e := e.(type) {
+	case *Unary:
+		Walk(e.X, f)
+	case *Binary:
+		Walk(e.X, f)
+		Walk(e.Y, f)
+	case *Call:
+		for _, arg := range e.Args {
+			Walk(arg, f)
+		}
+	case *Array:
+		for _, elem := range e.Elems {
+			Walk(elem, f)
+		}
+	}
+}
+
+// Rewrite returns a copy of e in which every node is replaced by f.
+// Children are rewritten before their parents and f receives a node
+// whose children are already rewritten.
+// f returns its argument to keep the node as is.
+func Rewrite(e Expr, f func(Expr) Expr) Expr {
+	switch x := e.(type) {
+	case *Unary:
+		e = &Unary{Op: x.Op, X: Rewrite(x.X, f)}
+	case *Binary:
+		e = &Binary{Op: x.Op, X: Rewrite(x.X, f), Y: Rewrite(x.Y, f)}
+	case *Call:
+		c := &Call{Func: x.Func, Args: make([]Expr, len(x.Args))}
+		for i, arg := range x.Args {
+			c.Args[i] = Rewrite(arg, f)
+		}
+		e = c
+	case *Array:
+		a := &Array{Elems: make([]Expr, len(x.Elems))}
+		for i, elem := range x.Elems {
+			a.Elems[i] = Rewrite(elem, f)
+		}
+		e = a
+	case *Column:
+		e = &Column{Name: x.Name}
+	case *Value:
+		e = &Value{Value: x.Value}
+	case nil:
+		return nil
+	}
+	return f(e)
+}
+
+// Columns returns the names of the columns referred to by e.
+// Each name appears once in order of appearance.
+func Columns(e Expr) []string {
+	var names []string
+	found := make(map[string]bool)
+	Walk(e, func(e Expr) bool {
+		if c, ok := e.(*Column); ok && !found[c.Name] {
+			found[c.Name] = true
+			names = append(names, c.Name)
+		}
+		return true
+	})
+	return names
+}
+
+// Validate checks that e is valid and the columns referred to by e exist in tbl.
+// A column name may be a path through references, such as user.name.
+func Validate(e Expr, schema *grnci.DBSchema, tbl string) error {
+	if e == nil {
+		return grnci.NewError(grnci.CommandError, "The expression must not be nil.", nil)
+	}
+	if err := e.check(); err != nil {
+		return err
+	}
+	for _, name := range Columns(e) {
+		if err := ValidateColumn(schema, tbl, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateColumn checks that the column path name exists in tbl.
+// The path may go through references, such as user.name.
+func ValidateColumn(schema *grnci.DBSchema, tbl, name string) error {
+	parts := strings.Split(name, ".")
+	cur := tbl
+	for i, part := range parts {
+		t, ok := schema.Tables[cur]
+		if !ok {
+			return grnci.NewError(grnci.CommandError, "The table does not exist.", map[string]interface{}{
+				"column": name,
+				"table":  cur,
+			})
+		}
+		var next string
+		switch part {
+		case "_id", "_score", "_nsubrecs", "_max", "_min", "_sum", "_avg":
+		case "_key":
+			if t.KeyType == nil {
+				return grnci.NewError(grnci.CommandError, "The table has no _key.", map[string]interface{}{
+					"column": name,
+					"table":  cur,
+				})
+			}
+			next = t.KeyType.Name
+		case "_value":
+			if t.ValueType == nil {
+				return grnci.NewError(grnci.CommandError, "The table has no _value.", map[string]interface{}{
+					"column": name,
+					"table":  cur,
+				})
+			}
+			next = t.ValueType.Name
+		default:
+			col, ok := t.Columns[part]
+			if !ok {
+				return grnci.NewError(grnci.CommandError, "The column does not exist.", map[string]interface{}{
+					"column": name,
+					"table":  cur,
+				})
+			}
+			next = col.ValueType.Name
+		}
+		if i == len(parts)-1 {
+			break
+		}
+		if _, ok := schema.Tables[next]; !ok {
+			return grnci.NewError(grnci.CommandError, "The column is not a reference.", map[string]interface{}{
+				"column": name,
+				"table":  cur,
+				"name":   part,
+			})
+		}
+		cur = next
+	}
+	return nil
+}
@@ -101,9 +101,16 @@ func (r *gqtpResponse) Read(p []byte) (int, error) {
 	}
 	n, err := r.conn.conn.Read(p)
 	r.left -= n
-	if err == io.EOF {
+	if err == io.EOF && r.left == 0 && r.head.Flags&gqtpFlagTail != 0 {
 		return n, io.EOF
 	}
+	if err == io.EOF {
+		// The connection is closed in the middle of the response.
+		r.conn.broken = true
+		return n, NewError(NetworkError, "The response is truncated.", map[string]interface{}{
+			"left": r.left,
+		})
+	}
 	if err != nil {
 		r.conn.broken = true
 		return n, NewError(NetworkError, "net.Conn.Read failed.", map[string]interface{}{
@@ -135,6 +142,7 @@ func (r *gqtpResponse) Close() error {
 			if e := r.conn.Close(); e != nil && err != nil {
 				err = e
 			}
+			return err
 		}
 		select {
 		case r.conn.client.idleConns <- r.conn:
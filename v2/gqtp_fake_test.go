@@ -0,0 +1,51 @@
+package grnci_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/groonga/grnci/v2"
+	"github.com/groonga/grnci/v2/grncitest"
+)
+
+func TestGQTPClientBrokenConnection(t *testing.T) {
+	s := grncitest.NewServer()
+	defer s.Close()
+	if err := s.StartGQTP(); err != nil {
+		t.Fatalf("s.StartGQTP failed: %v", err)
+	}
+	s.Handle("status", &grncitest.Reply{Body: `{"version":"9.0.0"}`, Truncate: 5})
+	s.Handle("status", grncitest.NewReply(`{"version":"9.0.0"}`))
+	client, err := grnci.NewGQTPClient(s.GQTPAddr(), nil)
+	if err != nil {
+		t.Fatalf("grnci.NewGQTPClient failed: %v", err)
+	}
+	defer client.Close()
+	resp, err := client.Exec("status", nil)
+	if err != nil {
+		t.Fatalf("client.Exec failed: %v", err)
+	}
+	if _, err := ioutil.ReadAll(resp); err == nil {
+		t.Fatalf("ioutil.ReadAll wrongly succeeded")
+	}
+	resp.Close()
+
+	// The broken connection must not be reused.
+	for i := 0; i < 2; i++ {
+		resp, err := client.Exec("status", nil)
+		if err != nil {
+			t.Fatalf("client.Exec failed: %v", err)
+		}
+		body, err := ioutil.ReadAll(resp)
+		if err != nil {
+			t.Fatalf("ioutil.ReadAll failed: %v", err)
+		}
+		if actual, want := string(body), `{"version":"9.0.0"}`; actual != want {
+			t.Fatalf("client.Exec failed: actual = %s, want = %s", actual, want)
+		}
+		if err := resp.Close(); err != nil {
+			t.Fatalf("resp.Close failed: %v", err)
+		}
+	}
+	s.AssertCommands(t, "status", "status", "status")
+}
@@ -0,0 +1,139 @@
+package grncitest
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+
+	"github.com/groonga/grnci/v2"
+)
+
+// Constants for gqtpHeader.
+const (
+	gqtpProtocol = byte(0xc7)
+	gqtpFlagTail = byte(0x02)
+
+	gqtpInvalidArgument = -22 // GRN_INVALID_ARGUMENT
+)
+
+// gqtpHeader is a GQTP header, the same as the one of grnci.
+type gqtpHeader struct {
+	Protocol  byte   // Must be 0xc7
+	QueryType byte   // Body type
+	KeyLength uint16 // Unused
+	Level     byte   // Unused
+	Flags     byte   // Flags
+	Status    uint16 // Return code
+	Size      uint32 // Body size
+	Opaque    uint32 // Unused
+	CAS       uint64 // Unused
+}
+
+// StartGQTP starts a GQTP server on a random local port.
+func (s *Server) StartGQTP() error {
+	addr, err := s.listen(s.serveGQTP)
+	if err != nil {
+		return err
+	}
+	s.gqtpAddr = addr
+	return nil
+}
+
+// serveGQTP accepts GQTP connections.
+func (s *Server) serveGQTP(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		if !s.track(conn, true) {
+			continue
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.track(conn, false)
+			defer conn.Close()
+			for s.handleGQTP(conn) {
+			}
+		}()
+	}
+}
+
+// readChunk reads a GQTP chunk.
+func readChunk(conn net.Conn) (gqtpHeader, []byte, error) {
+	var head gqtpHeader
+	if err := binary.Read(conn, binary.BigEndian, &head); err != nil {
+		return head, nil, err
+	}
+	if head.Protocol != gqtpProtocol {
+		return head, nil, grnci.NewError(grnci.InputError, "The protocol is unexpected.", map[string]interface{}{
+			"protocol": head.Protocol,
+		})
+	}
+	data := make([]byte, head.Size)
+	if _, err := io.ReadFull(conn, data); err != nil {
+		return head, nil, err
+	}
+	return head, data, nil
+}
+
+// writeChunk writes a GQTP chunk.
+// If the size is larger than len(data), the chunk is truncated.
+func writeChunk(conn net.Conn, status uint16, size int, data []byte) error {
+	head := gqtpHeader{
+		Protocol: gqtpProtocol,
+		Flags:    gqtpFlagTail,
+		Status:   status,
+		Size:     uint32(size),
+	}
+	if err := binary.Write(conn, binary.BigEndian, head); err != nil {
+		return err
+	}
+	_, err := conn.Write(data)
+	return err
+}
+
+// handleGQTP handles a command and returns whether or not
+// the connection is still available.
+func (s *Server) handleGQTP(conn net.Conn) bool {
+	head, data, err := readChunk(conn)
+	if err != nil {
+		return false
+	}
+	var body []byte
+	for last := head; last.Flags&gqtpFlagTail == 0; {
+		// Acknowledge the chunk and receive the next body chunk.
+		if err := writeChunk(conn, 0, 0, nil); err != nil {
+			return false
+		}
+		next, chunk, err := readChunk(conn)
+		if err != nil {
+			return false
+		}
+		body = append(body, chunk...)
+		last = next
+	}
+	cmd, err := grnci.ParseCommand(string(data))
+	if err != nil {
+		msg := []byte(err.Error())
+		return writeChunk(conn, errorCode(gqtpInvalidArgument), len(msg), msg) == nil
+	}
+	reply := s.reply(&Request{
+		Protocol: "gqtp",
+		Command:  cmd,
+		Body:     string(body),
+	})
+	switch {
+	case reply.Drop:
+		return false
+	case reply.Truncate > 0:
+		out := []byte(reply.Body)
+		if reply.Truncate < len(out) {
+			out = out[:reply.Truncate]
+		}
+		writeChunk(conn, errorCode(reply.Code), len(reply.Body), out)
+		return false
+	}
+	return writeChunk(conn, errorCode(reply.Code), len(reply.Body), []byte(reply.Body)) == nil
+}
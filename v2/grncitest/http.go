@@ -0,0 +1,85 @@
+package grncitest
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/groonga/grnci/v2"
+)
+
+// StartHTTP starts an HTTP server on a random local port.
+// Commands are accepted under /d/.
+func (s *Server) StartHTTP() error {
+	server := &http.Server{
+		Handler: http.HandlerFunc(s.serveHTTP),
+		ConnState: func(conn net.Conn, state http.ConnState) {
+			switch state {
+			case http.StateNew:
+				s.track(conn, true)
+			case http.StateHijacked, http.StateClosed:
+				s.track(conn, false)
+			}
+		},
+	}
+	addr, err := s.listen(func(ln net.Listener) {
+		server.Serve(ln)
+	})
+	if err != nil {
+		return err
+	}
+	s.httpAddr = "http://" + addr + "/d/"
+	return nil
+}
+
+// serveHTTP handles a command.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	if !strings.HasPrefix(r.URL.Path, "/d/") {
+		http.NotFound(w, r)
+		return
+	}
+	cmd, err := grnci.ParseCommandURL(r.URL.String())
+	if err != nil {
+		reply := NewErrorReply(gqtpInvalidArgument, err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("[" + errorHeader(reply, start) + "]"))
+		return
+	}
+	var body []byte
+	if r.Body != nil {
+		body, _ = ioutil.ReadAll(r.Body)
+	}
+	reply := s.reply(&Request{
+		Protocol: "http",
+		Command:  cmd,
+		Body:     string(body),
+	})
+	if reply.Drop {
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+			}
+		}
+		return
+	}
+	out := "[" + errorHeader(reply, start)
+	if reply.Body != "" {
+		out += "," + reply.Body
+	}
+	out += "]"
+	if reply.Truncate > 0 {
+		// The connection is closed because the body is shorter than Content-Length.
+		w.Header().Set("Content-Length", strconv.Itoa(len(out)))
+		if n := len(out) - len(reply.Body) - 1 + reply.Truncate; n < len(out) {
+			out = out[:n]
+		}
+	}
+	if reply.Code != 0 {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	w.Write([]byte(out))
+}
@@ -0,0 +1,229 @@
+// Package grncitest provides a fake Groonga server for tests.
+//
+// Server speaks both GQTP and the HTTP /d/ API in-process and
+// returns scripted replies, so that clients can be tested without groonga.
+// Replies can also inject errors, such as error return codes,
+// truncated responses, slow responses and dropped connections.
+package grncitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/groonga/grnci/v2"
+)
+
+// Reply is a scripted reply.
+type Reply struct {
+	Body     string        // Response body
+	Code     int           // Groonga return code (0 means success)
+	Message  string        // Error message for Code != 0
+	Delay    time.Duration // Delay before replying
+	Truncate int           // Number of body bytes sent before dropping the connection if > 0
+	Drop     bool          // Whether or not to drop the connection without replying
+}
+
+// NewReply returns a new successful Reply.
+func NewReply(body string) *Reply {
+	return &Reply{Body: body}
+}
+
+// NewErrorReply returns a new Reply with a Groonga return code.
+func NewErrorReply(code grnci.ErrorCode, msg string) *Reply {
+	return &Reply{
+		Code:    int(code),
+		Message: msg,
+	}
+}
+
+// Request is a received request.
+type Request struct {
+	Protocol string         // "gqtp" or "http"
+	Command  *grnci.Command // Command (the body is not available)
+	Body     string         // Command body
+}
+
+// Server is a fake Groonga server.
+type Server struct {
+	replies   map[string][]func(req *Request) *Reply
+	fallback  func(req *Request) *Reply
+	requests  []*Request
+	listeners []net.Listener
+	conns     map[net.Conn]struct{}
+	closed    bool
+	gqtpAddr  string
+	httpAddr  string
+	mutex     sync.Mutex
+	wg        sync.WaitGroup
+}
+
+// NewServer returns a new Server which replies true to any command.
+// Call StartGQTP or StartHTTP to accept requests.
+func NewServer() *Server {
+	return &Server{
+		replies: make(map[string][]func(req *Request) *Reply),
+		fallback: func(*Request) *Reply {
+			return NewReply("true")
+		},
+		conns: make(map[net.Conn]struct{}),
+	}
+}
+
+// Handle appends reply for the specified command.
+// Scripted replies are used in order, and the last one is repeated.
+// If name is empty, reply is used for commands without scripted replies.
+func (s *Server) Handle(name string, reply *Reply) {
+	s.HandleFunc(name, func(*Request) *Reply {
+		return reply
+	})
+}
+
+// HandleFunc is like Handle but computes the reply from the request.
+func (s *Server) HandleFunc(name string, f func(req *Request) *Reply) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if name == "" {
+		s.fallback = f
+		return
+	}
+	s.replies[name] = append(s.replies[name], f)
+}
+
+// reply records req and returns the reply for it.
+func (s *Server) reply(req *Request) *Reply {
+	s.mutex.Lock()
+	s.requests = append(s.requests, req)
+	f := s.fallback
+	if fs := s.replies[req.Command.Name()]; len(fs) != 0 {
+		f = fs[0]
+		if len(fs) > 1 {
+			s.replies[req.Command.Name()] = fs[1:]
+		}
+	}
+	s.mutex.Unlock()
+	reply := f(req)
+	if reply == nil {
+		reply = NewReply("true")
+	}
+	if reply.Delay > 0 {
+		time.Sleep(reply.Delay)
+	}
+	return reply
+}
+
+// Requests returns the received requests.
+func (s *Server) Requests() []*Request {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	requests := make([]*Request, len(s.requests))
+	copy(requests, s.requests)
+	return requests
+}
+
+// TestingT is the subset of testing.TB used by AssertCommands.
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+// AssertCommands reports an error via t unless the received commands,
+// in the form of Command.String, are the same as want.
+func (s *Server) AssertCommands(t TestingT, want ...string) bool {
+	requests := s.Requests()
+	ok := len(requests) == len(want)
+	for i := 0; ok && i < len(want); i++ {
+		ok = requests[i].Command.String() == want[i]
+	}
+	if !ok {
+		actual := make([]string, len(requests))
+		for i, req := range requests {
+			actual[i] = req.Command.String()
+		}
+		t.Errorf("grncitest: unexpected commands: actual = %q, want = %q", actual, want)
+	}
+	return ok
+}
+
+// listen listens on a random local port and starts serving.
+func (s *Server) listen(serve func(ln net.Listener)) (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", grnci.NewError(grnci.NetworkError, "net.Listen failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	s.mutex.Lock()
+	s.listeners = append(s.listeners, ln)
+	s.mutex.Unlock()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		serve(ln)
+	}()
+	return ln.Addr().String(), nil
+}
+
+// track adds or removes an open connection.
+// A connection opened after Close is closed immediately and track returns false.
+func (s *Server) track(conn net.Conn, open bool) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !open {
+		delete(s.conns, conn)
+		return true
+	}
+	if s.closed {
+		conn.Close()
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+// GQTPAddr returns the GQTP server address, such as 127.0.0.1:10043.
+func (s *Server) GQTPAddr() string {
+	return s.gqtpAddr
+}
+
+// HTTPAddr returns the HTTP server address, such as http://127.0.0.1:10041/d/.
+func (s *Server) HTTPAddr() string {
+	return s.httpAddr
+}
+
+// Close stops the server and closes open connections.
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	s.closed = true
+	var err error
+	for _, ln := range s.listeners {
+		if e := ln.Close(); e != nil && err == nil {
+			err = grnci.NewError(grnci.NetworkError, "net.Listener.Close failed.", map[string]interface{}{
+				"error": e.Error(),
+			})
+		}
+	}
+	s.listeners = nil
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// errorCode returns the GQTP status for the return code.
+func errorCode(code int) uint16 {
+	return uint16(int16(code))
+}
+
+// errorHeader returns the HTTP response header for reply.
+func errorHeader(reply *Reply, start time.Time) string {
+	elapsed := time.Since(start).Seconds()
+	if reply.Code == 0 {
+		return fmt.Sprintf("[0,%f,%f]", float64(start.UnixNano())/1e9, elapsed)
+	}
+	msg, _ := json.Marshal(reply.Message)
+	return fmt.Sprintf("[%d,%f,%f,%s]", reply.Code, float64(start.UnixNano())/1e9, elapsed, msg)
+}
@@ -0,0 +1,170 @@
+package grncitest
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/groonga/grnci/v2"
+)
+
+func newTestServer(t *testing.T) *Server {
+	s := NewServer()
+	if err := s.StartGQTP(); err != nil {
+		t.Fatalf("s.StartGQTP failed: %v", err)
+	}
+	if err := s.StartHTTP(); err != nil {
+		t.Fatalf("s.StartHTTP failed: %v", err)
+	}
+	s.Handle("status", NewReply(`{"version":"9.0.0"}`))
+	s.Handle("table_list", NewErrorReply(-22, "invalid argument"))
+	return s
+}
+
+func testClient(t *testing.T, s *Server, client grnci.Handler) {
+	db := grnci.NewDB(client)
+	result, err := db.Status()
+	if err != nil {
+		t.Fatalf("db.Status failed: %v", err)
+	}
+	if actual, want := result.Version, "9.0.0"; actual != want {
+		t.Fatalf("db.Status failed: actual = %s, want = %s", actual, want)
+	}
+	resp, err := db.Exec("load --table Tbl", strings.NewReader(`[{"_key":"a"}]`))
+	if err != nil {
+		t.Fatalf("db.Exec failed: %v", err)
+	}
+	if body, _ := ioutil.ReadAll(resp); string(body) != "true" {
+		t.Fatalf("db.Exec failed: actual = %s, want = %s", body, "true")
+	}
+	resp.Close()
+	if _, err := db.TableList(); err == nil {
+		t.Fatalf("db.TableList wrongly succeeded")
+	} else if e, ok := err.(*grnci.Error); !ok || e.Code != -22 {
+		t.Fatalf("db.TableList failed: %v", err)
+	}
+	s.AssertCommands(t, "status", "load --table 'Tbl'", "table_list")
+	if actual, want := s.Requests()[1].Body, `[{"_key":"a"}]`; actual != want {
+		t.Fatalf("s.Requests failed: actual = %s, want = %s", actual, want)
+	}
+}
+
+func TestGQTP(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	client, err := grnci.NewGQTPClient(s.GQTPAddr(), nil)
+	if err != nil {
+		t.Fatalf("grnci.NewGQTPClient failed: %v", err)
+	}
+	defer client.Close()
+	testClient(t, s, client)
+}
+
+func TestHTTP(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	client, err := grnci.NewHTTPClient(s.HTTPAddr(), nil)
+	if err != nil {
+		t.Fatalf("grnci.NewHTTPClient failed: %v", err)
+	}
+	defer client.Close()
+	testClient(t, s, client)
+}
+
+func TestBrokenConnection(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	if err := s.StartGQTP(); err != nil {
+		t.Fatalf("s.StartGQTP failed: %v", err)
+	}
+	s.Handle("select", &Reply{Body: "[[[0]]]", Truncate: 3})
+	s.Handle("select", &Reply{Drop: true})
+	s.Handle("select", NewReply("[[[0]]]"))
+	client, err := grnci.NewGQTPClient(s.GQTPAddr(), nil)
+	if err != nil {
+		t.Fatalf("grnci.NewGQTPClient failed: %v", err)
+	}
+	defer client.Close()
+	resp, err := client.Exec("select Tbl", nil)
+	if err != nil {
+		t.Fatalf("client.Exec failed: %v", err)
+	}
+	if _, err := ioutil.ReadAll(resp); err == nil {
+		t.Fatalf("ioutil.ReadAll wrongly succeeded")
+	}
+	resp.Close()
+	if _, err := client.Exec("select Tbl", nil); err == nil {
+		t.Fatalf("client.Exec wrongly succeeded")
+	}
+	resp, err = client.Exec("select Tbl", nil)
+	if err != nil {
+		t.Fatalf("client.Exec failed: %v", err)
+	}
+	if body, _ := ioutil.ReadAll(resp); string(body) != "[[[0]]]" {
+		t.Fatalf("client.Exec failed: actual = %s, want = %s", body, "[[[0]]]")
+	}
+	resp.Close()
+}
+
+func TestSlowResponse(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	if err := s.StartHTTP(); err != nil {
+		t.Fatalf("s.StartHTTP failed: %v", err)
+	}
+	s.Handle("", &Reply{Body: "true", Delay: 200 * time.Millisecond})
+	client, err := grnci.NewHTTPClient(s.HTTPAddr(), &http.Client{Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("grnci.NewHTTPClient failed: %v", err)
+	}
+	if _, err := client.Exec("status", nil); err == nil {
+		t.Fatalf("client.Exec wrongly succeeded")
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	if err := s.StartHTTP(); err != nil {
+		t.Fatalf("s.StartHTTP failed: %v", err)
+	}
+	msg := "invalid \"name\":\x01\té"
+	s.Handle("table_list", NewErrorReply(-22, msg))
+	client, err := grnci.NewHTTPClient(s.HTTPAddr(), nil)
+	if err != nil {
+		t.Fatalf("grnci.NewHTTPClient failed: %v", err)
+	}
+	defer client.Close()
+	_, err = grnci.NewDB(client).TableList()
+	e, ok := err.(*grnci.Error)
+	if !ok || e.Code != -22 {
+		t.Fatalf("db.TableList failed: %v", err)
+	}
+	if actual, want := e.Data["message"], msg; actual != want {
+		t.Fatalf("db.TableList failed: actual = %q, want = %q", actual, want)
+	}
+}
+
+func TestCloseLateConnection(t *testing.T) {
+	s := NewServer()
+	if err := s.StartGQTP(); err != nil {
+		t.Fatalf("s.StartGQTP failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("s.Close failed: %v", err)
+	}
+	client, server := net.Pipe()
+	defer client.Close()
+	if s.track(server, true) {
+		t.Fatalf("s.track wrongly succeeded")
+	}
+	if _, err := server.Write([]byte("x")); err == nil {
+		t.Fatalf("server.Write wrongly succeeded")
+	}
+	if n := len(s.conns); n != 0 {
+		t.Fatalf("s.track failed: conns = %d", n)
+	}
+}
@@ -0,0 +1,178 @@
+// Package guard provides a Handler to block commands by a policy.
+//
+// Handler wraps another Handler and checks every command against the rules
+// of a Policy before sending it. Blocked commands are rejected with
+// grnci.BlockedError and never reach the server.
+package guard
+
+import (
+	"io"
+
+	"github.com/groonga/grnci/v2"
+)
+
+// Action is an action of a Rule.
+type Action int
+
+const (
+	// Allow allows matched commands.
+	Allow Action = iota
+	// Deny blocks matched commands.
+	Deny
+)
+
+// Rule matches commands by name and parameters.
+type Rule struct {
+	Action  Action            // Action for matched commands
+	Command string            // Command name ("*" matches any command)
+	With    map[string]string // Parameters which must have the values ("" matches any value)
+	Without []string          // Parameters none of which may be present
+}
+
+// Match returns whether or not the rule matches cmd.
+func (r *Rule) Match(cmd *grnci.Command) bool {
+	if r.Command != "*" && r.Command != cmd.Name() {
+		return false
+	}
+	params := cmd.Params()
+	for k, v := range r.With {
+		value, ok := params[k]
+		if !ok || (v != "" && v != value) {
+			return false
+		}
+	}
+	for _, k := range r.Without {
+		if _, ok := params[k]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Policy is an ordered list of rules.
+// The first matched rule decides the action.
+type Policy struct {
+	Rules   []Rule // Rules in order of priority
+	Default Action // Action for commands which match no rules
+}
+
+// NewPolicy returns an empty Policy which allows all commands.
+func NewPolicy() *Policy {
+	return &Policy{
+		Default: Allow,
+	}
+}
+
+// DefaultPolicy returns a Policy which denies dangerous commands,
+// such as shutdown, table_remove and ruby_eval, and delete without
+// --key or --id.
+func DefaultPolicy() *Policy {
+	p := NewPolicy()
+	for _, ci := range grnci.Commands() {
+		if ci.Dangerous {
+			p.Deny(ci.Name)
+		}
+	}
+	p.DenyWithout("delete", "key", "id")
+	return p
+}
+
+// Allow appends a rule to allow the command.
+func (p *Policy) Allow(name string) *Policy {
+	p.Rules = append(p.Rules, Rule{Action: Allow, Command: name})
+	return p
+}
+
+// Deny appends a rule to deny the command.
+func (p *Policy) Deny(name string) *Policy {
+	p.Rules = append(p.Rules, Rule{Action: Deny, Command: name})
+	return p
+}
+
+// DenyWithout appends a rule to deny the command without any of the parameters.
+func (p *Policy) DenyWithout(name string, keys ...string) *Policy {
+	p.Rules = append(p.Rules, Rule{Action: Deny, Command: name, Without: keys})
+	return p
+}
+
+// Check returns an error with grnci.BlockedError if cmd is denied.
+func (p *Policy) Check(cmd *grnci.Command) error {
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if !r.Match(cmd) {
+			continue
+		}
+		if r.Action == Allow {
+			return nil
+		}
+		data := map[string]interface{}{
+			"name": cmd.Name(),
+			"rule": i,
+		}
+		if r.Without != nil {
+			data["without"] = r.Without
+		}
+		return grnci.NewError(grnci.BlockedError, "The command is blocked by the policy.", data)
+	}
+	if p.Default == Deny {
+		return grnci.NewError(grnci.BlockedError, "The command is not allowed by the policy.", map[string]interface{}{
+			"name": cmd.Name(),
+		})
+	}
+	return nil
+}
+
+// Handler is a Handler to block commands by a policy.
+type Handler struct {
+	h      grnci.Handler
+	policy *Policy
+}
+
+// NewHandler returns a new Handler to check commands sent via h.
+// If policy is nil, DefaultPolicy is used.
+func NewHandler(h grnci.Handler, policy *Policy) *Handler {
+	if policy == nil {
+		policy = DefaultPolicy()
+	}
+	return &Handler{
+		h:      h,
+		policy: policy,
+	}
+}
+
+// Exec parses cmd, checks the parsed command, sends it and returns the response.
+// It is the caller's responsibility to close the response.
+func (h *Handler) Exec(cmd string, body io.Reader) (grnci.Response, error) {
+	command, err := grnci.ParseCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	command.SetBody(body)
+	return h.Query(command)
+}
+
+// Invoke assembles name and params into a command,
+// checks the command, sends it and returns the response.
+// It is the caller's responsibility to close the response.
+func (h *Handler) Invoke(name string, params map[string]interface{}, body io.Reader) (grnci.Response, error) {
+	cmd, err := grnci.NewCommand(name, params)
+	if err != nil {
+		return nil, err
+	}
+	cmd.SetBody(body)
+	return h.Query(cmd)
+}
+
+// Query checks cmd, sends it and returns the response.
+// It is the caller's responsibility to close the response.
+func (h *Handler) Query(cmd *grnci.Command) (grnci.Response, error) {
+	if err := h.policy.Check(cmd); err != nil {
+		return nil, err
+	}
+	return h.h.Query(cmd)
+}
+
+// Close closes the underlying handler.
+func (h *Handler) Close() error {
+	return h.h.Close()
+}
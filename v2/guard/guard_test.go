@@ -0,0 +1,64 @@
+package guard
+
+import (
+	"testing"
+
+	"github.com/groonga/grnci/v2"
+	"github.com/groonga/grnci/v2/dryrun"
+)
+
+func TestHandler(t *testing.T) {
+	th := dryrun.NewHandler(nil)
+	policy := DefaultPolicy().DenyWithout("select", "limit")
+	h := NewHandler(th, policy)
+	allowed := []string{
+		`select Tbl --limit 10`,
+		`delete Tbl --key a`,
+		`delete Tbl --id 1`,
+		`status`,
+	}
+	for _, cmd := range allowed {
+		if _, err := h.Exec(cmd, nil); err != nil {
+			t.Fatalf("h.Exec failed: cmd = %s, err = %v", cmd, err)
+		}
+	}
+	denied := []string{
+		`select Tbl`,
+		`delete Tbl --filter 'true'`,
+		`shutdown`,
+		`table_remove Tbl`,
+		`ruby_eval 'exit'`,
+		`plugin_register functions/math`,
+		`database_unmap`,
+	}
+	for _, cmd := range denied {
+		_, err := h.Exec(cmd, nil)
+		if err == nil {
+			t.Fatalf("h.Exec wrongly succeeded: cmd = %s", cmd)
+		}
+		if e, ok := err.(*grnci.Error); !ok || e.Code != grnci.BlockedError {
+			t.Fatalf("h.Exec failed: cmd = %s, err = %v", cmd, err)
+		}
+	}
+	if n := len(th.Entries()); n != len(allowed) {
+		t.Fatalf("h.Exec failed: n = %d, want = %d", n, len(allowed))
+	}
+}
+
+func TestPolicyDefault(t *testing.T) {
+	p := NewPolicy().Allow("status")
+	p.Default = Deny
+	cmd, err := grnci.NewCommand("status", nil)
+	if err != nil {
+		t.Fatalf("grnci.NewCommand failed: %v", err)
+	}
+	if err := p.Check(cmd); err != nil {
+		t.Fatalf("p.Check failed: %v", err)
+	}
+	if cmd, err = grnci.NewCommand("table_list", nil); err != nil {
+		t.Fatalf("grnci.NewCommand failed: %v", err)
+	}
+	if err := p.Check(cmd); err == nil {
+		t.Fatalf("p.Check wrongly succeeded")
+	}
+}
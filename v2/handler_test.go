@@ -0,0 +1,83 @@
+package grnci
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// testResponse is a canned response for tests.
+type testResponse struct {
+	*bytes.Reader
+	err error
+}
+
+// newTestResponse returns a new testResponse.
+func newTestResponse(body string, err error) *testResponse {
+	return &testResponse{
+		Reader: bytes.NewReader([]byte(body)),
+		err:    err,
+	}
+}
+
+func (r *testResponse) Start() time.Time       { return time.Time{} }
+func (r *testResponse) Elapsed() time.Duration { return 0 }
+func (r *testResponse) Close() error           { return nil }
+func (r *testResponse) Err() error             { return r.err }
+
+// testHandler is a Handler that records commands and
+// returns responses generated by the callback.
+type testHandler struct {
+	mutex    sync.Mutex
+	commands []*Command
+	bodies   []string
+	callback func(cmd *Command, body string) (string, error)
+}
+
+// newTestHandler returns a new testHandler.
+func newTestHandler(callback func(cmd *Command, body string) (string, error)) *testHandler {
+	return &testHandler{callback: callback}
+}
+
+func (h *testHandler) Exec(cmd string, body io.Reader) (Response, error) {
+	command, err := ParseCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	command.SetBody(body)
+	return h.Query(command)
+}
+
+func (h *testHandler) Invoke(name string, params map[string]interface{}, body io.Reader) (Response, error) {
+	cmd, err := NewCommand(name, params)
+	if err != nil {
+		return nil, err
+	}
+	cmd.SetBody(body)
+	return h.Query(cmd)
+}
+
+func (h *testHandler) Query(cmd *Command) (Response, error) {
+	if err := cmd.Check(); err != nil {
+		return nil, err
+	}
+	var body []byte
+	if cmd.Body() != nil {
+		body, _ = ioutil.ReadAll(cmd.Body())
+	}
+	h.mutex.Lock()
+	h.commands = append(h.commands, cmd)
+	h.bodies = append(h.bodies, string(body))
+	h.mutex.Unlock()
+	if h.callback == nil {
+		return newTestResponse("true", nil), nil
+	}
+	result, err := h.callback(cmd, string(body))
+	return newTestResponse(result, err), nil
+}
+
+func (h *testHandler) Close() error {
+	return nil
+}
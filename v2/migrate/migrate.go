@@ -0,0 +1,824 @@
+// Package migrate provides a planner to migrate Groonga schemas.
+//
+// A plan is computed from the difference between the live schema
+// (the result of schema) and a desired schema, and consists of
+// table_rename, column_rename, column_remove, table_remove, table_create
+// and column_create commands in dependency order.
+package migrate
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/groonga/grnci/v2"
+)
+
+// builtinTypes is a set of the built-in type names.
+var builtinTypes = map[string]bool{
+	"Object":        true,
+	"Bool":          true,
+	"Int8":          true,
+	"Int16":         true,
+	"Int32":         true,
+	"Int64":         true,
+	"UInt8":         true,
+	"UInt16":        true,
+	"UInt32":        true,
+	"UInt64":        true,
+	"Float":         true,
+	"Float32":       true,
+	"Time":          true,
+	"ShortText":     true,
+	"Text":          true,
+	"LongText":      true,
+	"TokyoGeoPoint": true,
+	"WGS84GeoPoint": true,
+}
+
+// PlanOptions stores options for Diff and NewPlan.
+type PlanOptions struct {
+	RenameTables  map[string]string // Tables to be renamed (old name -> new name)
+	RenameColumns map[string]string // Columns to be renamed ("Table.old" -> "new")
+	DropTables    bool              // Remove tables missing in the desired schema
+	DropColumns   bool              // Remove columns missing in the desired schema
+	Recreate      bool              // Recreate incompatible tables and data columns
+}
+
+// NewPlanOptions returns the default PlanOptions.
+func NewPlanOptions() *PlanOptions {
+	return &PlanOptions{}
+}
+
+// Plan is an ordered list of commands to migrate a schema.
+type Plan struct {
+	Commands []*grnci.Command
+}
+
+// String returns the commands separated by newlines.
+func (p *Plan) String() string {
+	var buf bytes.Buffer
+	p.WriteTo(&buf)
+	return buf.String()
+}
+
+// WriteTo writes the commands into w as a Groonga command file.
+// The output is available for DB.Restore and the groonga command.
+func (p *Plan) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	for _, cmd := range p.Commands {
+		m, err := io.WriteString(w, cmd.String()+"\n")
+		n += int64(m)
+		if err != nil {
+			return n, grnci.NewError(grnci.OutputError, "io.WriteString failed.", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}
+	return n, nil
+}
+
+// Apply executes the commands in order and stops on the first failure.
+// Apply returns the number of commands successfully executed.
+func (p *Plan) Apply(db *grnci.DB) (int, error) {
+	for i, cmd := range p.Commands {
+		if err := apply(db, cmd); err != nil {
+			if e, ok := err.(*grnci.Error); ok {
+				e.Data["command"] = cmd.String()
+			}
+			return i, err
+		}
+	}
+	return len(p.Commands), nil
+}
+
+// apply executes cmd and checks the response.
+func apply(db *grnci.DB, cmd *grnci.Command) error {
+	resp, err := db.Query(cmd)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(ioutil.Discard, resp)
+	if e := resp.Close(); e != nil && err == nil {
+		err = e
+	}
+	if e := resp.Err(); e != nil {
+		return e
+	}
+	return err
+}
+
+// NewPlan reads the live schema from db and returns a plan to migrate it to desired.
+func NewPlan(db *grnci.DB, desired *grnci.DBSchema, options *PlanOptions) (*Plan, error) {
+	current, err := db.Schema()
+	if err != nil {
+		return nil, err
+	}
+	return Diff(current, desired, options)
+}
+
+// planner stores the intermediate state of Diff.
+type planner struct {
+	current  map[string]*grnci.DBSchemaTable // Live tables after renames
+	desired  map[string]*grnci.DBSchemaTable // Desired tables
+	types    map[string]bool                 // Available type names
+	options  *PlanOptions
+	commands []*grnci.Command
+}
+
+// Diff compares current with desired and returns a plan to migrate current to desired.
+func Diff(current, desired *grnci.DBSchema, options *PlanOptions) (*Plan, error) {
+	if options == nil {
+		options = NewPlanOptions()
+	}
+	p := &planner{
+		current: make(map[string]*grnci.DBSchemaTable),
+		desired: make(map[string]*grnci.DBSchemaTable),
+		types:   make(map[string]bool),
+		options: options,
+	}
+	for name := range builtinTypes {
+		p.types[name] = true
+	}
+	if current != nil {
+		for name := range current.Types {
+			p.types[name] = true
+		}
+		for name, tbl := range current.Tables {
+			p.current[name] = copyTable(name, tbl)
+		}
+	}
+	if desired != nil {
+		for name, tbl := range desired.Tables {
+			p.desired[name] = copyTable(name, tbl)
+		}
+	}
+	if err := p.checkDesired(); err != nil {
+		return nil, err
+	}
+	if err := p.rename(); err != nil {
+		return nil, err
+	}
+	if err := p.plan(); err != nil {
+		return nil, err
+	}
+	return &Plan{Commands: p.commands}, nil
+}
+
+// copyTable returns a copy of tbl whose columns can be modified
+// without affecting the caller's schema.
+func copyTable(name string, tbl grnci.DBSchemaTable) *grnci.DBSchemaTable {
+	tbl.Name = name
+	columns := make(map[string]grnci.DBSchemaColumn, len(tbl.Columns))
+	for colName, col := range tbl.Columns {
+		col.Sources = append([]grnci.DBSchemaSource(nil), col.Sources...)
+		columns[colName] = col
+	}
+	tbl.Columns = columns
+	return &tbl
+}
+
+// add appends a new command to the plan.
+func (p *planner) add(name string, params map[string]interface{}) error {
+	cmd, err := grnci.NewCommand(name, params)
+	if err != nil {
+		return err
+	}
+	p.commands = append(p.commands, cmd)
+	return nil
+}
+
+// isType returns whether or not name is available as a type in the desired schema.
+func (p *planner) isType(name string) bool {
+	if _, ok := p.desired[name]; ok {
+		return true
+	}
+	return p.types[name]
+}
+
+// checkDesired checks that the desired schema refers to available types.
+func (p *planner) checkDesired() error {
+	for _, name := range sortedNames(p.desired) {
+		tbl := p.desired[name]
+		for _, dep := range tableDeps(tbl) {
+			if !p.isType(dep) {
+				return grnci.NewError(grnci.TypeError, "The type is not defined.", map[string]interface{}{
+					"table": name,
+					"type":  dep,
+				})
+			}
+		}
+		for _, colName := range sortedColumnNames(tbl) {
+			col := tbl.Columns[colName]
+			if !p.isType(col.ValueType.Name) {
+				return grnci.NewError(grnci.TypeError, "The type is not defined.", map[string]interface{}{
+					"column": name + "." + colName,
+					"type":   col.ValueType.Name,
+				})
+			}
+			if col.Type == "index" {
+				srcTbl, ok := p.desired[col.ValueType.Name]
+				if !ok {
+					return grnci.NewError(grnci.TypeError, "The source table is not defined.", map[string]interface{}{
+						"column": name + "." + colName,
+						"type":   col.ValueType.Name,
+					})
+				}
+				for _, src := range col.Sources {
+					if _, ok := srcTbl.Columns[src.Name]; !ok && src.Name != "_key" {
+						return grnci.NewError(grnci.TypeError, "The source column is not defined.", map[string]interface{}{
+							"column": name + "." + colName,
+							"source": col.ValueType.Name + "." + src.Name,
+						})
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// rename emits table_rename and column_rename and
+// applies the renames to the live tables.
+func (p *planner) rename() error {
+	oldNames := make([]string, 0, len(p.options.RenameTables))
+	for name := range p.options.RenameTables {
+		oldNames = append(oldNames, name)
+	}
+	sort.Strings(oldNames)
+	for _, oldName := range oldNames {
+		newName := p.options.RenameTables[oldName]
+		tbl, ok := p.current[oldName]
+		if !ok {
+			return grnci.NewError(grnci.OperationError, "The table does not exist.", map[string]interface{}{
+				"name": oldName,
+			})
+		}
+		if _, ok := p.current[newName]; ok {
+			return grnci.NewError(grnci.OperationError, "The table already exists.", map[string]interface{}{
+				"name": newName,
+			})
+		}
+		if err := p.add("table_rename", map[string]interface{}{
+			"name":     oldName,
+			"new_name": newName,
+		}); err != nil {
+			return err
+		}
+		delete(p.current, oldName)
+		tbl.Name = newName
+		p.current[newName] = tbl
+		for _, t := range p.current {
+			renameTableRefs(t, oldName, newName)
+		}
+	}
+
+	oldNames = oldNames[:0]
+	for name := range p.options.RenameColumns {
+		oldNames = append(oldNames, name)
+	}
+	sort.Strings(oldNames)
+	for _, oldName := range oldNames {
+		newName := p.options.RenameColumns[oldName]
+		i := strings.IndexByte(oldName, '.')
+		if i == -1 {
+			return grnci.NewError(grnci.CommandError, "The name must contain a dot.", map[string]interface{}{
+				"name": oldName,
+			})
+		}
+		tblName, colName := oldName[:i], oldName[i+1:]
+		tbl, ok := p.current[tblName]
+		if !ok {
+			return grnci.NewError(grnci.OperationError, "The table does not exist.", map[string]interface{}{
+				"name": tblName,
+			})
+		}
+		col, ok := tbl.Columns[colName]
+		if !ok {
+			return grnci.NewError(grnci.OperationError, "The column does not exist.", map[string]interface{}{
+				"name": oldName,
+			})
+		}
+		if _, ok := tbl.Columns[newName]; ok {
+			return grnci.NewError(grnci.OperationError, "The column already exists.", map[string]interface{}{
+				"name": tblName + "." + newName,
+			})
+		}
+		if err := p.add("column_rename", map[string]interface{}{
+			"table":    tblName,
+			"name":     colName,
+			"new_name": newName,
+		}); err != nil {
+			return err
+		}
+		delete(tbl.Columns, colName)
+		col.Name = newName
+		col.FullName = tblName + "." + newName
+		tbl.Columns[newName] = col
+		for _, t := range p.current {
+			renameSourceRefs(t, tblName, colName, newName)
+		}
+	}
+	return nil
+}
+
+// plan emits commands to remove and create tables and columns.
+func (p *planner) plan() error {
+	// Detect tables to be removed or recreated.
+	removedTables := make(map[string]bool)
+	for _, name := range sortedNames(p.current) {
+		cur := p.current[name]
+		want, ok := p.desired[name]
+		switch {
+		case !ok:
+			if p.options.DropTables {
+				removedTables[name] = true
+			}
+		case !equalTables(cur, want):
+			if !p.options.Recreate && !isLexicon(cur) {
+				return grnci.NewError(grnci.OperationError, "The table must be recreated.", map[string]interface{}{
+					"name": name,
+				})
+			}
+			removedTables[name] = true
+		}
+	}
+	// Tables depending on removed tables must also be removed.
+	for changed := true; changed; {
+		changed = false
+		for _, name := range sortedNames(p.current) {
+			if removedTables[name] {
+				continue
+			}
+			for _, dep := range tableDeps(p.current[name]) {
+				if !removedTables[dep] {
+					continue
+				}
+				if _, ok := p.desired[name]; !ok {
+					return grnci.NewError(grnci.OperationError, "The table depends on a removed table.", map[string]interface{}{
+						"name":       name,
+						"dependency": dep,
+					})
+				}
+				if !p.options.Recreate && !isLexicon(p.current[name]) {
+					return grnci.NewError(grnci.OperationError, "The table must be recreated.", map[string]interface{}{
+						"name":       name,
+						"dependency": dep,
+					})
+				}
+				removedTables[name] = true
+				changed = true
+				break
+			}
+		}
+	}
+
+	// Detect columns to be removed or recreated in the remaining tables.
+	// Data columns are checked first because index columns
+	// must be recreated if their source columns are removed.
+	var removedIndexes, removedColumns []*grnci.DBSchemaColumn
+	removed := make(map[string]bool)
+	createdColumns := make(map[string]bool)
+	for _, index := range []bool{false, true} {
+		for _, name := range sortedNames(p.current) {
+			if removedTables[name] {
+				continue
+			}
+			cur := p.current[name]
+			want, wanted := p.desired[name]
+			for _, colName := range sortedColumnNames(cur) {
+				col := cur.Columns[colName]
+				if (col.Type == "index") != index {
+					continue
+				}
+				col.Table = name
+				col.Name = colName
+				remove := removedTables[col.ValueType.Name]
+				for _, src := range col.Sources {
+					if removed[col.ValueType.Name+"."+src.Name] {
+						remove = true
+					}
+				}
+				if wanted {
+					if wantCol, ok := want.Columns[colName]; !ok {
+						remove = remove || p.options.DropColumns
+					} else if remove || !equalColumns(&col, &wantCol) {
+						if !index && !p.options.Recreate {
+							return grnci.NewError(grnci.OperationError, "The column must be recreated.", map[string]interface{}{
+								"name": name + "." + colName,
+							})
+						}
+						remove = true
+						createdColumns[name+"."+colName] = true
+					}
+				}
+				if !remove {
+					continue
+				}
+				removed[name+"."+colName] = true
+				if index {
+					removedIndexes = append(removedIndexes, &col)
+				} else {
+					removedColumns = append(removedColumns, &col)
+				}
+			}
+		}
+	}
+
+	// Remove index columns first because they refer to other columns.
+	for _, col := range append(removedIndexes, removedColumns...) {
+		if err := p.add("column_remove", map[string]interface{}{
+			"table": col.Table,
+			"name":  col.Name,
+		}); err != nil {
+			return err
+		}
+	}
+
+	// Remove tables in reverse dependency order.
+	order, err := sortTables(p.current, removedTables)
+	if err != nil {
+		return err
+	}
+	for i := len(order) - 1; i >= 0; i-- {
+		if err := p.add("table_remove", map[string]interface{}{
+			"name": order[i],
+		}); err != nil {
+			return err
+		}
+	}
+
+	// Create tables in dependency order.
+	createdTables := make(map[string]bool)
+	for name := range p.desired {
+		if _, ok := p.current[name]; !ok || removedTables[name] {
+			createdTables[name] = true
+		}
+	}
+	order, err = sortTables(p.desired, createdTables)
+	if err != nil {
+		return err
+	}
+	for _, name := range order {
+		params, err := tableCreateParams(p.desired[name])
+		if err != nil {
+			return err
+		}
+		if err := p.add("table_create", params); err != nil {
+			return err
+		}
+	}
+
+	// Create data columns and then index columns.
+	var indexes []*grnci.DBSchemaColumn
+	for _, name := range sortedNames(p.desired) {
+		want := p.desired[name]
+		cur := p.current[name]
+		for _, colName := range sortedColumnNames(want) {
+			col := want.Columns[colName]
+			col.Table = name
+			col.Name = colName
+			if !createdTables[name] && !createdColumns[name+"."+colName] {
+				if _, ok := cur.Columns[colName]; ok {
+					continue
+				}
+			}
+			if col.Type == "index" {
+				indexes = append(indexes, &col)
+				continue
+			}
+			if err := p.add("column_create", columnCreateParams(&col)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, col := range indexes {
+		if err := p.add("column_create", columnCreateParams(col)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedNames returns the sorted table names.
+func sortedNames(tables map[string]*grnci.DBSchemaTable) []string {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedColumnNames returns the sorted column names of tbl.
+func sortedColumnNames(tbl *grnci.DBSchemaTable) []string {
+	names := make([]string, 0, len(tbl.Columns))
+	for name := range tbl.Columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// tableDeps returns the names of types that tbl refers to.
+func tableDeps(tbl *grnci.DBSchemaTable) []string {
+	var deps []string
+	if tbl.KeyType != nil && tbl.KeyType.Name != "" {
+		deps = append(deps, tbl.KeyType.Name)
+	}
+	if tbl.ValueType != nil && tbl.ValueType.Name != "" {
+		deps = append(deps, tbl.ValueType.Name)
+	}
+	return deps
+}
+
+// sortTables returns the names of the target tables in dependency order,
+// that is, referenced tables come first.
+func sortTables(tables map[string]*grnci.DBSchemaTable, targets map[string]bool) ([]string, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := make(map[string]int)
+	var order []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch states[name] {
+		case visiting:
+			return grnci.NewError(grnci.OperationError, "The tables have a circular dependency.", map[string]interface{}{
+				"name": name,
+			})
+		case visited:
+			return nil
+		}
+		states[name] = visiting
+		for _, dep := range tableDeps(tables[name]) {
+			if _, ok := tables[dep]; ok && dep != name {
+				if err := visit(dep); err != nil {
+					return err
+				}
+			}
+		}
+		states[name] = visited
+		if targets[name] {
+			order = append(order, name)
+		}
+		return nil
+	}
+	for _, name := range sortedNames(tables) {
+		if targets[name] {
+			if err := visit(name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return order, nil
+}
+
+// renameTableRefs replaces references to oldName in tbl with newName.
+func renameTableRefs(tbl *grnci.DBSchemaTable, oldName, newName string) {
+	if tbl.KeyType != nil && tbl.KeyType.Name == oldName {
+		keyType := *tbl.KeyType
+		keyType.Name = newName
+		tbl.KeyType = &keyType
+	}
+	if tbl.ValueType != nil && tbl.ValueType.Name == oldName {
+		valueType := *tbl.ValueType
+		valueType.Name = newName
+		tbl.ValueType = &valueType
+	}
+	columns := make(map[string]grnci.DBSchemaColumn, len(tbl.Columns))
+	for name, col := range tbl.Columns {
+		if col.ValueType.Name == oldName {
+			col.ValueType.Name = newName
+		}
+		sources := make([]grnci.DBSchemaSource, len(col.Sources))
+		for i, src := range col.Sources {
+			if src.Table == oldName {
+				src.Table = newName
+			}
+			sources[i] = src
+		}
+		col.Sources = sources
+		columns[name] = col
+	}
+	tbl.Columns = columns
+}
+
+// renameSourceRefs replaces references to tblName.oldName in tbl with tblName.newName.
+func renameSourceRefs(tbl *grnci.DBSchemaTable, tblName, oldName, newName string) {
+	columns := make(map[string]grnci.DBSchemaColumn, len(tbl.Columns))
+	for name, col := range tbl.Columns {
+		if col.Type == "index" && col.ValueType.Name == tblName {
+			sources := make([]grnci.DBSchemaSource, len(col.Sources))
+			for i, src := range col.Sources {
+				if src.Name == oldName {
+					src.Name = newName
+				}
+				sources[i] = src
+			}
+			col.Sources = sources
+		}
+		columns[name] = col
+	}
+	tbl.Columns = columns
+}
+
+// isLexicon returns whether or not tbl has only index columns.
+// Such a table can be recreated without data loss.
+func isLexicon(tbl *grnci.DBSchemaTable) bool {
+	if len(tbl.Columns) == 0 {
+		return false
+	}
+	for _, col := range tbl.Columns {
+		if col.Type != "index" {
+			return false
+		}
+	}
+	return true
+}
+
+// tableType returns the normalized table type.
+func tableType(tbl *grnci.DBSchemaTable) string {
+	if tbl.Type != "" {
+		return tbl.Type
+	}
+	if tbl.KeyType != nil && tbl.KeyType.Name != "" {
+		return "hash table"
+	}
+	return "array"
+}
+
+// keyTypeName returns the name of a key type.
+func keyTypeName(t *grnci.DBSchemaKeyType) string {
+	if t == nil {
+		return ""
+	}
+	return t.Name
+}
+
+// valueTypeName returns the name of a value type.
+func valueTypeName(t *grnci.DBSchemaValueType) string {
+	if t == nil {
+		return ""
+	}
+	return t.Name
+}
+
+// tokenizerName returns the name of a tokenizer.
+func tokenizerName(t *grnci.DBSchemaTokenizer) string {
+	if t == nil {
+		return ""
+	}
+	return t.Name
+}
+
+// normalizerName returns the name of a normalizer.
+func normalizerName(n *grnci.DBSchemaNormalizer) string {
+	if n == nil {
+		return ""
+	}
+	return n.Name
+}
+
+// tokenFilterNames returns the names of token filters.
+func tokenFilterNames(tfs []grnci.DBSchemaTokenFilter) []string {
+	var names []string
+	for _, tf := range tfs {
+		names = append(names, tf.Name)
+	}
+	return names
+}
+
+// sourceNames returns the names of source columns.
+func sourceNames(srcs []grnci.DBSchemaSource) []string {
+	var names []string
+	for _, src := range srcs {
+		names = append(names, src.Name)
+	}
+	return names
+}
+
+// equalStrings returns whether or not a and b have the same elements.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// equalTables returns whether or not a and b have the same table definition.
+// Columns are not compared.
+func equalTables(a, b *grnci.DBSchemaTable) bool {
+	return tableType(a) == tableType(b) &&
+		keyTypeName(a.KeyType) == keyTypeName(b.KeyType) &&
+		valueTypeName(a.ValueType) == valueTypeName(b.ValueType) &&
+		tokenizerName(a.Tokenizer) == tokenizerName(b.Tokenizer) &&
+		normalizerName(a.Normalizer) == normalizerName(b.Normalizer) &&
+		equalStrings(tokenFilterNames(a.TokenFilters), tokenFilterNames(b.TokenFilters))
+}
+
+// columnType returns the normalized column type.
+func columnType(col *grnci.DBSchemaColumn) string {
+	if col.Type == "" {
+		return "scalar"
+	}
+	return col.Type
+}
+
+// equalColumns returns whether or not a and b have the same column definition.
+func equalColumns(a, b *grnci.DBSchemaColumn) bool {
+	return columnType(a) == columnType(b) &&
+		a.ValueType.Name == b.ValueType.Name &&
+		a.Compress == b.Compress &&
+		a.Section == b.Section &&
+		a.Weight == b.Weight &&
+		a.Position == b.Position &&
+		equalStrings(sourceNames(a.Sources), sourceNames(b.Sources))
+}
+
+// tableCreateParams returns parameters of table_create for tbl.
+func tableCreateParams(tbl *grnci.DBSchemaTable) (map[string]interface{}, error) {
+	params := map[string]interface{}{
+		"name": tbl.Name,
+	}
+	switch typ := tableType(tbl); typ {
+	case "array":
+		params["flags"] = "TABLE_NO_KEY"
+	case "hash table":
+		params["flags"] = "TABLE_HASH_KEY"
+	case "patricia trie":
+		params["flags"] = "TABLE_PAT_KEY"
+	case "double array trie":
+		params["flags"] = "TABLE_DAT_KEY"
+	default:
+		return nil, grnci.NewError(grnci.TypeError, "The table type is not supported.", map[string]interface{}{
+			"name": tbl.Name,
+			"type": typ,
+		})
+	}
+	if name := keyTypeName(tbl.KeyType); name != "" {
+		params["key_type"] = name
+	}
+	if name := valueTypeName(tbl.ValueType); name != "" {
+		params["value_type"] = name
+	}
+	if name := tokenizerName(tbl.Tokenizer); name != "" {
+		params["default_tokenizer"] = name
+	}
+	if name := normalizerName(tbl.Normalizer); name != "" {
+		params["normalizer"] = name
+	}
+	if names := tokenFilterNames(tbl.TokenFilters); names != nil {
+		params["token_filters"] = names
+	}
+	return params, nil
+}
+
+// columnCreateParams returns parameters of column_create for col.
+func columnCreateParams(col *grnci.DBSchemaColumn) map[string]interface{} {
+	var flags []string
+	switch columnType(col) {
+	case "vector":
+		flags = append(flags, "COLUMN_VECTOR")
+	case "index":
+		flags = append(flags, "COLUMN_INDEX")
+	default:
+		flags = append(flags, "COLUMN_SCALAR")
+	}
+	switch col.Compress {
+	case "zlib":
+		flags = append(flags, "COMPRESS_ZLIB")
+	case "lz4":
+		flags = append(flags, "COMPRESS_LZ4")
+	case "zstd":
+		flags = append(flags, "COMPRESS_ZSTD")
+	}
+	if col.Section {
+		flags = append(flags, "WITH_SECTION")
+	}
+	if col.Weight {
+		flags = append(flags, "WITH_WEIGHT")
+	}
+	if col.Position {
+		flags = append(flags, "WITH_POSITION")
+	}
+	params := map[string]interface{}{
+		"table": col.Table,
+		"name":  col.Name,
+		"flags": flags,
+		"type":  col.ValueType.Name,
+	}
+	if names := sourceNames(col.Sources); names != nil {
+		params["source"] = names
+	}
+	return params
+}
@@ -0,0 +1,247 @@
+package migrate
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/groonga/grnci/v2"
+)
+
+type testUser struct {
+	Key  string `grnci:"_key"`
+	Name string `grnci:"name;Text"`
+	Age  int32  `grnci:"age"`
+}
+
+type testPost struct {
+	ID     uint32   `grnci:"_id"`
+	Title  string   `grnci:"title;Text"`
+	Tags   []string `grnci:"tags"`
+	Author string   `grnci:"author;Users"`
+}
+
+type testTerm struct {
+	Key string `grnci:"_key;ShortText;TABLE_PAT_KEY;TokenBigram;NormalizerAuto"`
+}
+
+func testDesiredSchema(t *testing.T) *grnci.DBSchema {
+	schema := NewSchema()
+	if err := AddStruct(schema, "Users", testUser{}); err != nil {
+		t.Fatalf("AddStruct failed: %v", err)
+	}
+	if err := AddStruct(schema, "Posts", []testPost{}); err != nil {
+		t.Fatalf("AddStruct failed: %v", err)
+	}
+	if err := AddStruct(schema, "Terms", &testTerm{}); err != nil {
+		t.Fatalf("AddStruct failed: %v", err)
+	}
+	if err := AddIndex(schema, "Terms.posts_title", "Posts", []string{"title"}, []string{"WITH_POSITION"}); err != nil {
+		t.Fatalf("AddIndex failed: %v", err)
+	}
+	if err := AddIndex(schema, "Users.posts_author", "Posts", []string{"author"}, nil); err != nil {
+		t.Fatalf("AddIndex failed: %v", err)
+	}
+	return schema
+}
+
+func TestTableFromStruct(t *testing.T) {
+	tbl, err := TableFromStruct("Terms", testTerm{})
+	if err != nil {
+		t.Fatalf("TableFromStruct failed: %v", err)
+	}
+	if want := "patricia trie"; tbl.Type != want {
+		t.Fatalf("TableFromStruct failed: Type = %s, want = %s", tbl.Type, want)
+	}
+	if want := "TokenBigram"; tbl.Tokenizer == nil || tbl.Tokenizer.Name != want {
+		t.Fatalf("TableFromStruct failed: Tokenizer = %#v, want = %s", tbl.Tokenizer, want)
+	}
+
+	tbl, err = TableFromStruct("Posts", testPost{})
+	if err != nil {
+		t.Fatalf("TableFromStruct failed: %v", err)
+	}
+	if want := "array"; tbl.Type != want {
+		t.Fatalf("TableFromStruct failed: Type = %s, want = %s", tbl.Type, want)
+	}
+	if col := tbl.Columns["tags"]; col.Type != "vector" || col.ValueType.Name != "ShortText" {
+		t.Fatalf("TableFromStruct failed: tags = %#v", col)
+	}
+	if _, ok := tbl.Columns["_id"]; ok {
+		t.Fatalf("TableFromStruct failed: _id must be ignored")
+	}
+}
+
+func TestDiffCreate(t *testing.T) {
+	plan, err := Diff(nil, testDesiredSchema(t), nil)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	// Referenced tables come first and index columns come last.
+	want := []string{
+		"table_create --flags 'TABLE_NO_KEY' --name 'Posts'",
+		"table_create --default_tokenizer 'TokenBigram' --flags 'TABLE_PAT_KEY' --key_type 'ShortText' --name 'Terms' --normalizer 'NormalizerAuto'",
+		"table_create --flags 'TABLE_HASH_KEY' --key_type 'ShortText' --name 'Users'",
+		"column_create --flags 'COLUMN_SCALAR' --name 'author' --table 'Posts' --type 'Users'",
+		"column_create --flags 'COLUMN_VECTOR' --name 'tags' --table 'Posts' --type 'ShortText'",
+		"column_create --flags 'COLUMN_SCALAR' --name 'title' --table 'Posts' --type 'Text'",
+		"column_create --flags 'COLUMN_SCALAR' --name 'age' --table 'Users' --type 'Int32'",
+		"column_create --flags 'COLUMN_SCALAR' --name 'name' --table 'Users' --type 'Text'",
+		"column_create --flags 'COLUMN_INDEX|WITH_POSITION' --name 'posts_title' --source 'title' --table 'Terms' --type 'Posts'",
+		"column_create --flags 'COLUMN_INDEX' --name 'posts_author' --source 'author' --table 'Users' --type 'Posts'",
+	}
+	if actual := strings.TrimSpace(plan.String()); actual != strings.Join(want, "\n") {
+		t.Fatalf("Diff failed: actual = %s, want = %s", actual, strings.Join(want, "\n"))
+	}
+}
+
+func TestDiffNoChange(t *testing.T) {
+	desired := testDesiredSchema(t)
+	plan, err := Diff(desired, desired, nil)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(plan.Commands) != 0 {
+		t.Fatalf("Diff failed: plan = %s", plan)
+	}
+}
+
+func TestDiffIndex(t *testing.T) {
+	current := testDesiredSchema(t)
+	desired := testDesiredSchema(t)
+	if err := AddIndex(desired, "Terms.posts_title", "Posts", []string{"title"}, nil); err != nil {
+		t.Fatalf("AddIndex failed: %v", err)
+	}
+	plan, err := Diff(current, desired, nil)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	want := "column_remove --name 'posts_title' --table 'Terms'\n" +
+		"column_create --flags 'COLUMN_INDEX' --name 'posts_title' --source 'title' --table 'Terms' --type 'Posts'\n"
+	if actual := plan.String(); actual != want {
+		t.Fatalf("Diff failed: actual = %s, want = %s", actual, want)
+	}
+}
+
+func TestDiffRecreate(t *testing.T) {
+	current := testDesiredSchema(t)
+	desired := testDesiredSchema(t)
+	tbl := desired.Tables["Users"]
+	col := tbl.Columns["age"]
+	col.ValueType.Name = "Int64"
+	tbl.Columns["age"] = col
+	if _, err := Diff(current, desired, nil); err == nil {
+		t.Fatalf("Diff wrongly succeeded")
+	}
+	options := NewPlanOptions()
+	options.Recreate = true
+	plan, err := Diff(current, desired, options)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	want := "column_remove --name 'age' --table 'Users'\n" +
+		"column_create --flags 'COLUMN_SCALAR' --name 'age' --table 'Users' --type 'Int64'\n"
+	if actual := plan.String(); actual != want {
+		t.Fatalf("Diff failed: actual = %s, want = %s", actual, want)
+	}
+}
+
+func TestDiffDrop(t *testing.T) {
+	current := testDesiredSchema(t)
+	desired := testDesiredSchema(t)
+	delete(desired.Tables, "Terms")
+	options := NewPlanOptions()
+	plan, err := Diff(current, desired, options)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(plan.Commands) != 0 {
+		t.Fatalf("Diff failed: plan = %s", plan)
+	}
+	options.DropTables = true
+	plan, err = Diff(current, desired, options)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if want := "table_remove --name 'Terms'\n"; plan.String() != want {
+		t.Fatalf("Diff failed: actual = %s, want = %s", plan, want)
+	}
+}
+
+func TestDiffRename(t *testing.T) {
+	current := testDesiredSchema(t)
+	tbl := current.Tables["Users"]
+	col := tbl.Columns["name"]
+	delete(tbl.Columns, "name")
+	col.Name = "nick"
+	tbl.Columns["nick"] = col
+	current.Tables["Members"] = tbl
+	delete(current.Tables, "Users")
+	posts := current.Tables["Posts"]
+	author := posts.Columns["author"]
+	author.ValueType.Name = "Members"
+	posts.Columns["author"] = author
+
+	options := NewPlanOptions()
+	options.RenameTables = map[string]string{"Members": "Users"}
+	options.RenameColumns = map[string]string{"Users.nick": "name"}
+	plan, err := Diff(current, testDesiredSchema(t), options)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	want := "table_rename --name 'Members' --new_name 'Users'\n" +
+		"column_rename --name 'nick' --new_name 'name' --table 'Users'\n"
+	if actual := plan.String(); actual != want {
+		t.Fatalf("Diff failed: actual = %s, want = %s", actual, want)
+	}
+}
+
+func TestDiffRenameColumnKeepsInputs(t *testing.T) {
+	current := testDesiredSchema(t)
+	tbl := current.Tables["Users"]
+	col := tbl.Columns["name"]
+	delete(tbl.Columns, "name")
+	col.Name = "nick"
+	tbl.Columns["nick"] = col
+	desired := testDesiredSchema(t)
+	currentJSON, _ := json.Marshal(current)
+	desiredJSON, _ := json.Marshal(desired)
+
+	options := NewPlanOptions()
+	options.RenameColumns = map[string]string{"Users.nick": "name"}
+	plan, err := Diff(current, desired, options)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	want := "column_rename --name 'nick' --new_name 'name' --table 'Users'\n"
+	if actual := plan.String(); actual != want {
+		t.Fatalf("Diff failed: actual = %s, want = %s", actual, want)
+	}
+	if actual, _ := json.Marshal(current); string(actual) != string(currentJSON) {
+		t.Fatalf("Diff modified current: actual = %s, want = %s", actual, currentJSON)
+	}
+	if actual, _ := json.Marshal(desired); string(actual) != string(desiredJSON) {
+		t.Fatalf("Diff modified desired: actual = %s, want = %s", actual, desiredJSON)
+	}
+}
+
+func TestDiffTableOrder(t *testing.T) {
+	desired := NewSchema()
+	desired.Tables["Alias"] = grnci.DBSchemaTable{
+		Type:    "hash table",
+		KeyType: &grnci.DBSchemaKeyType{Name: "Names"},
+	}
+	desired.Tables["Names"] = grnci.DBSchemaTable{
+		Type:    "patricia trie",
+		KeyType: &grnci.DBSchemaKeyType{Name: "ShortText"},
+	}
+	plan, err := Diff(nil, desired, nil)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	want := "table_create --flags 'TABLE_PAT_KEY' --key_type 'ShortText' --name 'Names'\n" +
+		"table_create --flags 'TABLE_HASH_KEY' --key_type 'Names' --name 'Alias'\n"
+	if actual := plan.String(); actual != want {
+		t.Fatalf("Diff failed: actual = %s, want = %s", actual, want)
+	}
+}
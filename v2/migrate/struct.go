@@ -0,0 +1,159 @@
+package migrate
+
+import (
+	"strings"
+
+	"github.com/groonga/grnci/v2"
+)
+
+// NewSchema returns an empty desired schema.
+func NewSchema() *grnci.DBSchema {
+	return &grnci.DBSchema{
+		Tables: make(map[string]grnci.DBSchemaTable),
+	}
+}
+
+// AddStruct adds a table definition derived from the struct tags of v to schema.
+// See TableFromStruct for details.
+func AddStruct(schema *grnci.DBSchema, name string, v interface{}) error {
+	tbl, err := TableFromStruct(name, v)
+	if err != nil {
+		return err
+	}
+	if schema.Tables == nil {
+		schema.Tables = make(map[string]grnci.DBSchemaTable)
+	}
+	schema.Tables[name] = *tbl
+	return nil
+}
+
+// AddIndex adds an index column to schema.
+// The lexicon must be added before AddIndex.
+// WITH_SECTION is enabled if srcs contains more than one column.
+func AddIndex(schema *grnci.DBSchema, name, srcTable string, srcs []string, flags []string) error {
+	i := strings.IndexByte(name, '.')
+	if i == -1 {
+		return grnci.NewError(grnci.CommandError, "The name must contain a dot.", map[string]interface{}{
+			"name": name,
+		})
+	}
+	tbl, ok := schema.Tables[name[:i]]
+	if !ok {
+		return grnci.NewError(grnci.CommandError, "The table does not exist.", map[string]interface{}{
+			"name": name[:i],
+		})
+	}
+	col := grnci.DBSchemaColumn{
+		Name:     name[i+1:],
+		Table:    name[:i],
+		FullName: name,
+		Type:     "index",
+		ValueType: grnci.DBSchemaValueType{
+			Name: srcTable,
+			Type: "reference",
+		},
+		Section: len(srcs) > 1,
+	}
+	for _, flag := range flags {
+		switch flag {
+		case "WITH_SECTION":
+			col.Section = true
+		case "WITH_WEIGHT":
+			col.Weight = true
+		case "WITH_POSITION":
+			col.Position = true
+		}
+	}
+	for _, src := range srcs {
+		col.Sources = append(col.Sources, grnci.DBSchemaSource{
+			Name:     src,
+			Table:    srcTable,
+			FullName: srcTable + "." + src,
+		})
+	}
+	columns := make(map[string]grnci.DBSchemaColumn, len(tbl.Columns)+1)
+	for k, v := range tbl.Columns {
+		columns[k] = v
+	}
+	columns[col.Name] = col
+	tbl.Columns = columns
+	schema.Tables[name[:i]] = tbl
+	return nil
+}
+
+// TableFromStruct returns a table definition derived from the struct tags of v.
+// v must be a struct, a pointer to a struct or a slice of structs.
+// The tag format is the same as DB.LoadRows and DB.SelectRows.
+// Pseudo columns except _key and _value, and non-loadable columns are ignored.
+func TableFromStruct(name string, v interface{}) (*grnci.DBSchemaTable, error) {
+	rs, err := grnci.GetRowStruct(v)
+	if err != nil {
+		return nil, err
+	}
+	tbl := &grnci.DBSchemaTable{
+		Name:    name,
+		Type:    "array",
+		Columns: make(map[string]grnci.DBSchemaColumn),
+	}
+	for _, cf := range rs.Columns {
+		switch cf.Name {
+		case "_id", "_score":
+		case "_key":
+			tbl.Type = "hash table"
+			for _, flag := range cf.Flags {
+				switch flag {
+				case "TABLE_HASH_KEY":
+					tbl.Type = "hash table"
+				case "TABLE_PAT_KEY":
+					tbl.Type = "patricia trie"
+				case "TABLE_DAT_KEY":
+					tbl.Type = "double array trie"
+				}
+			}
+			tbl.KeyType = &grnci.DBSchemaKeyType{Name: cf.Type}
+			if cf.DefaultTokenizer != "" {
+				tbl.Tokenizer = &grnci.DBSchemaTokenizer{Name: cf.DefaultTokenizer}
+			}
+			if cf.Normalizer != "" {
+				tbl.Normalizer = &grnci.DBSchemaNormalizer{Name: cf.Normalizer}
+			}
+			for _, tf := range cf.TokenFilters {
+				tbl.TokenFilters = append(tbl.TokenFilters, grnci.DBSchemaTokenFilter{Name: tf})
+			}
+		case "_value":
+			tbl.ValueType = &grnci.DBSchemaValueType{Name: cf.Type}
+		default:
+			if !cf.Loadable {
+				continue
+			}
+			col := grnci.DBSchemaColumn{
+				Name:     cf.Name,
+				Table:    name,
+				FullName: name + "." + cf.Name,
+				Type:     "scalar",
+			}
+			typ := cf.Type
+			if strings.HasPrefix(typ, "[]") {
+				col.Type = "vector"
+				typ = typ[2:]
+			}
+			col.ValueType.Name = typ
+			for _, flag := range cf.Flags {
+				switch flag {
+				case "COLUMN_VECTOR":
+					col.Type = "vector"
+				case "COMPRESS_ZLIB":
+					col.Compress = "zlib"
+				case "COMPRESS_LZ4":
+					col.Compress = "lz4"
+				case "COMPRESS_ZSTD":
+					col.Compress = "zstd"
+				case "WITH_WEIGHT":
+					col.Weight = true
+				}
+			}
+			tbl.Columns[cf.Name] = col
+		}
+	}
+	return tbl, nil
+}
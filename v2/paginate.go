@@ -0,0 +1,286 @@
+package grnci
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// DBPaginateOptions stores options for DB.Paginate.
+type DBPaginateOptions struct {
+	MatchColumns  []string // --match_columns
+	Query         string   // --query
+	QueryFlags    []string // --query_flags
+	Filter        string   // --filter
+	OutputColumns []string // --output_columns
+	SortKey       string   // Unique sort key (_id if empty, prefix "-" for descending order)
+	PageSize      int      // --limit
+	Cursor        string   // Cursor to resume (DBPaginator.Cursor)
+}
+
+// NewDBPaginateOptions returns the default DBPaginateOptions.
+func NewDBPaginateOptions() *DBPaginateOptions {
+	return &DBPaginateOptions{
+		SortKey:  "_id",
+		PageSize: 100,
+	}
+}
+
+// dbCursor is the content of a cursor token.
+type dbCursor struct {
+	Table   string          `json:"t"`
+	SortKey string          `json:"k"`
+	Last    json.RawMessage `json:"v,omitempty"`
+}
+
+// DBPaginator iterates over records with keyset pagination.
+// Each page is fetched by select with a --filter bound on the sort key,
+// so deep pages are as fast as the first page and records loaded during
+// the iteration do not shift pages.
+type DBPaginator struct {
+	db      *DB
+	tbl     string
+	options *DBPaginateOptions
+	column  string          // Sort key column without the order prefix
+	desc    bool            // Whether or not the order is descending
+	last    json.RawMessage // Sort key value of the last record
+	done    bool            // Whether or not the last page has been read
+}
+
+// Paginate returns a DBPaginator to iterate over records in tbl.
+// SortKey must be unique in tbl, otherwise records may be skipped.
+func (db *DB) Paginate(tbl string, options *DBPaginateOptions) (*DBPaginator, error) {
+	if options == nil {
+		options = NewDBPaginateOptions()
+	}
+	p := &DBPaginator{
+		db:      db,
+		tbl:     tbl,
+		options: options,
+		column:  options.SortKey,
+	}
+	if p.column == "" {
+		p.column = "_id"
+	}
+	if strings.HasPrefix(p.column, "-") {
+		p.column = p.column[1:]
+		p.desc = true
+	}
+	if options.PageSize <= 0 {
+		return nil, NewError(CommandError, "The page size must be positive.", map[string]interface{}{
+			"pageSize": options.PageSize,
+		})
+	}
+	if options.Cursor != "" {
+		data, err := base64.RawURLEncoding.DecodeString(options.Cursor)
+		if err != nil {
+			return nil, NewError(InputError, "The cursor is broken.", map[string]interface{}{
+				"cursor": options.Cursor,
+				"error":  err.Error(),
+			})
+		}
+		var cursor dbCursor
+		if err := json.Unmarshal(data, &cursor); err != nil {
+			return nil, NewError(InputError, "The cursor is broken.", map[string]interface{}{
+				"cursor": options.Cursor,
+				"error":  err.Error(),
+			})
+		}
+		if cursor.Table != tbl || cursor.SortKey != options.SortKey {
+			return nil, NewError(InputError, "The cursor does not match the options.", map[string]interface{}{
+				"cursor":  options.Cursor,
+				"table":   tbl,
+				"sortKey": options.SortKey,
+			})
+		}
+		if cursor.Last != nil {
+			if _, err := formatCursorValue(cursor.Last); err != nil {
+				err.Data["cursor"] = options.Cursor
+				return nil, err
+			}
+		}
+		p.last = cursor.Last
+	}
+	return p, nil
+}
+
+// Cursor returns an opaque token to resume the iteration after the last read page.
+func (p *DBPaginator) Cursor() string {
+	data, _ := json.Marshal(&dbCursor{
+		Table:   p.tbl,
+		SortKey: p.options.SortKey,
+		Last:    p.last,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// formatCursorValue returns a sort key value in the script syntax.
+// Only a JSON number or string is accepted.
+func formatCursorValue(data json.RawMessage) (string, *Error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err == nil && !decoder.More() {
+		switch v := v.(type) {
+		case string:
+			// Strings are re-encoded because JSON escapes differ from script syntax.
+			return string(AppendJSONString(nil, v)), nil
+		case json.Number:
+			if i, err := v.Int64(); err == nil {
+				return strconv.FormatInt(i, 10), nil
+			}
+			if f, err := v.Float64(); err == nil {
+				return strconv.FormatFloat(f, 'f', -1, 64), nil
+			}
+		}
+	}
+	return "", NewError(InputError, "The sort key value must be a number or a string.", map[string]interface{}{
+		"value": string(data),
+	})
+}
+
+// filter returns --filter of the next page.
+func (p *DBPaginator) filter() (string, error) {
+	if p.last == nil {
+		return p.options.Filter, nil
+	}
+	value, err := formatCursorValue(p.last)
+	if err != nil {
+		return "", err
+	}
+	var buf []byte
+	if p.options.Filter != "" {
+		buf = append(buf, '(')
+		buf = append(buf, p.options.Filter...)
+		buf = append(buf, ") && "...)
+	}
+	buf = append(buf, p.column...)
+	if p.desc {
+		buf = append(buf, " < "...)
+	} else {
+		buf = append(buf, " > "...)
+	}
+	return string(append(buf, value...)), nil
+}
+
+// nextSet fetches the next page and returns the raw record set.
+func (p *DBPaginator) nextSet() ([][]json.RawMessage, error) {
+	if p.done {
+		return nil, io.EOF
+	}
+	options := NewDBSelectOptions()
+	options.MatchColumns = p.options.MatchColumns
+	options.Query = p.options.Query
+	options.QueryFlags = p.options.QueryFlags
+	filter, err := p.filter()
+	if err != nil {
+		return nil, err
+	}
+	options.Filter = filter
+	options.SortKeys = []string{p.options.SortKey}
+	if p.options.SortKey == "" {
+		options.SortKeys = []string{"_id"}
+	}
+	options.Limit = p.options.PageSize
+	if p.options.OutputColumns != nil {
+		options.OutputColumns = p.options.OutputColumns
+		found := false
+		for _, col := range options.OutputColumns {
+			if col == p.column {
+				found = true
+			}
+		}
+		if !found {
+			options.OutputColumns = append(append([]string(nil), options.OutputColumns...), p.column)
+		}
+	}
+	result, err := p.db.Select(p.tbl, options)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+	data, err := ioutil.ReadAll(result)
+	if err != nil {
+		return nil, err
+	}
+	var raw [][][]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	if len(raw) == 0 || len(raw[0]) < 2 {
+		return nil, NewError(ResponseError, "The response is empty.", nil)
+	}
+	set := raw[0]
+	recs := set[2:]
+	if len(recs) < p.options.PageSize {
+		p.done = true
+	}
+	if len(recs) == 0 {
+		return nil, io.EOF
+	}
+	idx := -1
+	for i, rawCol := range set[1] {
+		var nameType []string
+		if err := json.Unmarshal(rawCol, &nameType); err != nil {
+			return nil, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		if len(nameType) != 0 && nameType[0] == p.column {
+			idx = i
+		}
+	}
+	if idx == -1 {
+		return nil, NewError(ResponseError, "The sort key is not in the output columns.", map[string]interface{}{
+			"sortKey": p.column,
+		})
+	}
+	p.last = json.RawMessage(bytes.TrimSpace(recs[len(recs)-1][idx]))
+	return set, nil
+}
+
+// NextRecords returns the next page.
+// If there are no more records, it returns io.EOF.
+func (p *DBPaginator) NextRecords() (*DBRecords, error) {
+	set, err := p.nextSet()
+	if err != nil {
+		return nil, err
+	}
+	return p.db.parseRecords(set)
+}
+
+// NextRows decodes the next page into rows.
+// The fields of rows are associated with output columns by name.
+// If there are no more records, it returns io.EOF.
+func (p *DBPaginator) NextRows(rows interface{}) (int, error) {
+	rs, err := GetRowStruct(rows)
+	if err != nil {
+		return 0, err
+	}
+	set, err := p.nextSet()
+	if err != nil {
+		return 0, err
+	}
+	cfs := make([]*ColumnField, len(set[1]))
+	for i, rawCol := range set[1] {
+		var nameType []string
+		if err := json.Unmarshal(rawCol, &nameType); err != nil {
+			return 0, NewError(ResponseError, "json.Unmarshal failed.", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		if len(nameType) != 0 {
+			cfs[i] = rs.ColumnsByName[nameType[0]]
+		}
+	}
+	if _, err := p.db.decodeRows(rows, set, cfs); err != nil {
+		return 0, err
+	}
+	return len(set) - 2, nil
+}
@@ -0,0 +1,95 @@
+package grnci
+
+import (
+	"encoding/base64"
+	"io"
+	"testing"
+)
+
+func TestDBPaginate(t *testing.T) {
+	h := newTestHandler(func(cmd *Command, body string) (string, error) {
+		params := cmd.Params()
+		if params["sort_keys"] != "_key" || params["limit"] != "2" {
+			return "", NewError(CommandError, "Unexpected params.", map[string]interface{}{"params": params})
+		}
+		switch params["filter"] {
+		case `tag == "a"`:
+			return `[[[5],[["_key","ShortText"],["n","Int32"]],["a\"1",1],["b",2]]]`, nil
+		case `(tag == "a") && _key > "b"`:
+			return `[[[1],[["_key","ShortText"],["n","Int32"]],["c",3]]]`, nil
+		}
+		return "", NewError(CommandError, "Unexpected filter.", map[string]interface{}{"filter": params["filter"]})
+	})
+	db := NewDB(h)
+	options := NewDBPaginateOptions()
+	options.Filter = `tag == "a"`
+	options.SortKey = "_key"
+	options.PageSize = 2
+	p, err := db.Paginate("Tbl", options)
+	if err != nil {
+		t.Fatalf("db.Paginate failed: %v", err)
+	}
+	type Row struct {
+		Key string `grnci:"_key"`
+		N   int    `grnci:"n"`
+	}
+	var rows []Row
+	if n, err := p.NextRows(&rows); err != nil || n != 2 {
+		t.Fatalf("p.NextRows failed: n = %d, err = %v", n, err)
+	}
+	if actual, want := rows[0], (Row{"a\"1", 1}); actual != want {
+		t.Fatalf("p.NextRows failed: actual = %#v, want = %#v", actual, want)
+	}
+	cursor := p.Cursor()
+
+	// Resume from the cursor.
+	options.Cursor = cursor
+	p, err = db.Paginate("Tbl", options)
+	if err != nil {
+		t.Fatalf("db.Paginate failed: %v", err)
+	}
+	records, err := p.NextRecords()
+	if err != nil {
+		t.Fatalf("p.NextRecords failed: %v", err)
+	}
+	if actual, want := records.Records[0]["_key"], "c"; actual != want {
+		t.Fatalf("p.NextRecords failed: actual = %v, want = %v", actual, want)
+	}
+	if _, err := p.NextRecords(); err != io.EOF {
+		t.Fatalf("p.NextRecords failed: err = %v", err)
+	}
+
+	options.SortKey = "-_key"
+	if _, err := db.Paginate("Tbl", options); err == nil {
+		t.Fatalf("db.Paginate succeeded with a mismatched cursor")
+	}
+}
+
+func TestDBPaginateTamperedCursor(t *testing.T) {
+	h := newTestHandler(func(cmd *Command, body string) (string, error) {
+		if actual, want := cmd.Params()["filter"], "_id > 10"; actual != want {
+			return "", NewError(CommandError, "Unexpected filter.", map[string]interface{}{"filter": actual})
+		}
+		return `[[[0],[["_id","UInt32"]]]]`, nil
+	})
+	db := NewDB(h)
+	options := NewDBPaginateOptions()
+	cursor := func(v string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(`{"t":"Tbl","k":"_id","v":` + v + `}`))
+	}
+	options.Cursor = cursor("10")
+	p, err := db.Paginate("Tbl", options)
+	if err != nil {
+		t.Fatalf("db.Paginate failed: %v", err)
+	}
+	if _, err := p.NextRecords(); err != io.EOF {
+		t.Fatalf("p.NextRecords failed: err = %v", err)
+	}
+	for _, v := range []string{`{"x":1}`, `[1,2]`, `true`, `null1`} {
+		options.Cursor = cursor(v)
+		_, err := db.Paginate("Tbl", options)
+		if e, ok := err.(*Error); !ok || e.Code != InputError {
+			t.Fatalf("db.Paginate failed: v = %s, err = %v", v, err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package grnci
+
+import (
+	"strings"
+)
+
+// QuoteQuery escapes the metacharacters of query syntax in s.
+//
+// Whitespace is kept as is and the words of s are combined by AND.
+// Operators such as "OR", "-", "+" and "~", phrases, parentheses,
+// prefix search ("*"), column qualifiers ("column:") and pragmas
+// are escaped with '\' and searched literally.
+// Use the result as --query of select.
+func QuoteQuery(s string) string {
+	buf := make([]byte, 0, len(s)+len(s)/4)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\', '"', '\'', '(', ')', '+', '-', '~', '<', '>', '*', ':':
+			buf = append(buf, '\\')
+		case 'O':
+			// "OR" as a word is an operator.
+			if i+1 < len(s) && s[i+1] == 'R' &&
+				(i == 0 || isQuerySpace(s[i-1])) &&
+				(i+2 == len(s) || isQuerySpace(s[i+2])) {
+				buf = append(buf, '\\')
+			}
+		}
+		buf = append(buf, s[i])
+	}
+	return string(buf)
+}
+
+// QuoteQueryPhrase returns s as a phrase of query syntax.
+func QuoteQueryPhrase(s string) string {
+	buf := make([]byte, 0, len(s)+2)
+	buf = append(buf, '"')
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\', '"':
+			buf = append(buf, '\\')
+		}
+		buf = append(buf, s[i])
+	}
+	return string(append(buf, '"'))
+}
+
+// isQuerySpace returns whether or not c is a separator of query syntax.
+func isQuerySpace(c byte) bool {
+	return strings.IndexByte(" \t\n\r\v\f", c) != -1
+}
@@ -0,0 +1,275 @@
+// Package query provides a builder for Groonga query syntax.
+//
+// A Query is available as --query of select together with the flags
+// returned by Query.Flags.
+//
+//	q := query.And(
+//		query.Term(userInput),
+//		query.Not(query.Column("tag", "", "draft")),
+//	)
+//	options := grnci.NewDBSelectOptions()
+//	if err := q.Apply(options, query.Strict); err != nil {
+//		return err
+//	}
+//
+// Terms and values are escaped with grnci.QuoteQuery or
+// grnci.QuoteQueryPhrase and compound queries are parenthesized unless
+// they come first, so user input never changes the structure of a query.
+// Invalid column qualifiers are reported by Format and Query.Apply.
+package query
+
+import (
+	"strings"
+
+	"github.com/groonga/grnci/v2"
+)
+
+// Query precedences.
+const (
+	precOr = iota + 1
+	precAnd
+	precTerm
+)
+
+// Query is a query in query syntax.
+type Query struct {
+	s           string
+	prec        int
+	allowColumn bool // Whether or not the query contains column qualifiers
+	leadingNot  bool // Whether or not the query starts with "-"
+	empty       bool // Whether or not the query is empty
+	err         error
+}
+
+// String returns the query.
+func (q Query) String() string {
+	return q.s
+}
+
+// Format validates q and returns the query.
+func Format(q Query) (string, error) {
+	if q.err != nil {
+		return "", q.err
+	}
+	return q.s, nil
+}
+
+// Mode is a parsing mode of --query.
+type Mode int
+
+const (
+	// Strict enables only the query_flags required by the query and
+	// lets Groonga report syntax errors.
+	Strict Mode = iota
+	// Permissive enables the default query_flags (ALLOW_PRAGMA and ALLOW_COLUMN)
+	// and QUERY_NO_SYNTAX_ERROR, so syntax errors are searched literally.
+	Permissive
+)
+
+// Flags returns --query_flags for q.
+func (q Query) Flags(mode Mode) []string {
+	var flags []string
+	switch mode {
+	case Permissive:
+		flags = append(flags, "ALLOW_PRAGMA", "ALLOW_COLUMN")
+		if q.leadingNot {
+			flags = append(flags, "ALLOW_LEADING_NOT")
+		}
+		flags = append(flags, "QUERY_NO_SYNTAX_ERROR")
+	default:
+		if q.allowColumn {
+			flags = append(flags, "ALLOW_COLUMN")
+		}
+		if q.leadingNot {
+			flags = append(flags, "ALLOW_LEADING_NOT")
+		}
+		if flags == nil {
+			flags = append(flags, "NONE")
+		}
+	}
+	return flags
+}
+
+// Apply validates q and sets q and its flags to options.
+func (q Query) Apply(options *grnci.DBSelectOptions, mode Mode) error {
+	s, err := Format(q)
+	if err != nil {
+		return err
+	}
+	options.Query = s
+	options.QueryFlags = q.Flags(mode)
+	return nil
+}
+
+// Raw returns s as a query without escaping.
+// The query is assumed to contain column qualifiers.
+func Raw(s string) Query {
+	return Query{s: s, prec: precOr, allowColumn: true, empty: s == ""}
+}
+
+// Term returns a query to search words in s.
+// The metacharacters in s are escaped and the words are combined by AND.
+func Term(s string) Query {
+	s = grnci.QuoteQuery(strings.TrimSpace(s))
+	prec := precTerm
+	if strings.IndexAny(s, " \t\n\r\v\f") != -1 {
+		prec = precAnd
+	}
+	return Query{s: s, prec: prec, empty: s == ""}
+}
+
+// Phrase returns a query to search s as a phrase.
+func Phrase(s string) Query {
+	return Query{s: grnci.QuoteQueryPhrase(s), prec: precTerm}
+}
+
+// Prefix returns a query to search words starting with s (s*).
+func Prefix(s string) Query {
+	return Query{s: quoteWord(s) + "*", prec: precTerm}
+}
+
+// quoteWord returns s as a single word.
+func quoteWord(s string) string {
+	if s == "" || strings.IndexAny(s, " \t\n\r\v\f") != -1 {
+		return grnci.QuoteQueryPhrase(s)
+	}
+	return grnci.QuoteQuery(s)
+}
+
+// columnOps is the set of operators available in Column.
+var columnOps = map[string]bool{
+	"": true, "!": true, "@": true, "^": true, "$": true,
+	"<": true, ">": true, "<=": true, ">=": true, "~": true,
+}
+
+// checkColumn checks if col is valid as a column name, such as user.name.
+func checkColumn(col string) error {
+	if col == "" {
+		return grnci.NewError(grnci.CommandError, "The column name must not be empty.", nil)
+	}
+	if col[0] >= '0' && col[0] <= '9' {
+		return grnci.NewError(grnci.CommandError, "The column name must not start with a digit.", map[string]interface{}{
+			"name": col,
+		})
+	}
+	for i := 0; i < len(col); i++ {
+		switch c := col[i]; {
+		case c >= '0' && c <= '9':
+		case c >= 'A' && c <= 'Z':
+		case c >= 'a' && c <= 'z':
+		case c == '_', c == '.':
+		default:
+			return grnci.NewError(grnci.CommandError, "The column name must consist of [0-9A-Za-z_.].", map[string]interface{}{
+				"name": col,
+			})
+		}
+	}
+	return nil
+}
+
+// Column returns a column-qualified query, such as title:@groonga.
+// op is one of "" (equal), "!", "@" (match), "^" (prefix), "$" (suffix),
+// "<", ">", "<=", ">=" and "~" (regular expression).
+// value is quoted as a single word.
+// An invalid col or op is reported by Format.
+func Column(col, op, value string) Query {
+	q := Query{s: col + ":" + op + quoteWord(value), prec: precTerm, allowColumn: true}
+	if err := checkColumn(col); err != nil {
+		q.err = err
+	} else if !columnOps[op] {
+		q.err = grnci.NewError(grnci.CommandError, "The operator is not supported.", map[string]interface{}{
+			"op": op,
+		})
+	}
+	return q
+}
+
+// Boost returns a query with the adjusted weight of q.
+// If n is positive, the weight is increased by n steps (">").
+// If n is negative, the weight is decreased by -n steps ("<").
+func Boost(q Query, n int) Query {
+	if n == 0 || q.empty {
+		return q
+	}
+	prefix := ">"
+	if n < 0 {
+		prefix = "<"
+		n = -n
+	}
+	q.s = strings.Repeat(prefix, n) + group(q, precTerm)
+	q.prec = precTerm
+	return q
+}
+
+// group encloses q in parentheses if its precedence is lower than prec.
+func group(q Query, prec int) string {
+	if q.prec < prec {
+		return "(" + q.s + ")"
+	}
+	return q.s
+}
+
+// join joins qs with sep.
+// Groonga parses implicit AND and OR from left to right at the same
+// precedence, so compound queries other than the first one are enclosed
+// in parentheses.
+func join(sep string, prec int, qs []Query) Query {
+	var result Query
+	var parts []string
+	for _, q := range qs {
+		if q.err != nil && result.err == nil {
+			result.err = q.err
+		}
+		if q.empty {
+			continue
+		}
+		if len(parts) == 0 {
+			result.leadingNot = q.leadingNot
+			parts = append(parts, group(q, prec))
+		} else {
+			parts = append(parts, group(q, precTerm))
+		}
+		result.allowColumn = result.allowColumn || q.allowColumn
+	}
+	switch len(parts) {
+	case 0:
+		result.empty = true
+	case 1:
+		for _, q := range qs {
+			if !q.empty {
+				if q.err == nil {
+					q.err = result.err
+				}
+				return q
+			}
+		}
+	}
+	result.s = strings.Join(parts, sep)
+	result.prec = prec
+	return result
+}
+
+// And returns a query to search records matching all of qs.
+// Empty queries are ignored.
+func And(qs ...Query) Query {
+	return join(" ", precAnd, qs)
+}
+
+// Or returns a query to search records matching any of qs.
+// Empty queries are ignored.
+func Or(qs ...Query) Query {
+	return join(" OR ", precOr, qs)
+}
+
+// Not returns a query to exclude records matching q.
+// It must be combined with And and not be the first query,
+// otherwise ALLOW_LEADING_NOT is required.
+func Not(q Query) Query {
+	if q.empty {
+		return q
+	}
+	q.s = "-" + group(q, precTerm)
+	q.prec = precTerm
+	q.leadingNot = true
+	return q
+}
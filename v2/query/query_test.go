@@ -0,0 +1,87 @@
+package query
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/groonga/grnci/v2"
+)
+
+func TestQuery(t *testing.T) {
+	data := map[string]Query{
+		`groonga`:               Term("groonga"),
+		`groonga mroonga`:       Term(" groonga mroonga "),
+		`\(a\) \OR \-b c\:d`:    Term("(a) OR -b c:d"),
+		`"say \"hi\""`:          Phrase(`say "hi"`),
+		`gro*`:                  Prefix("gro"),
+		`"a b"*`:                Prefix("a b"),
+		`title:@groonga`:        Column("title", "@", "groonga"),
+		`title:"a b"`:           Column("title", "", "a b"),
+		`a b OR c`:              Or(Term("a b"), Term("c")),
+		`(a b OR c) d`:          And(Or(Term("a b"), Term("c")), Term("d")),
+		`c OR (a b)`:            Or(Term("c"), Term("a b")),
+		`a (b OR c) (d e)`:      And(Term("a"), Or(Term("b"), Term("c")), Term("d e")),
+		`a -b -(c OR d)`:        And(Term("a"), Not(Term("b")), Not(Or(Term("c"), Term("d")))),
+		`>>a <(b c)`:            And(Boost(Term("a"), 2), Boost(Term("b c"), -1)),
+		`a`:                     And(Term(""), Term("a"), Term(" ")),
+		``:                      Or(),
+		`tag:^go OR (\* \*D\+)`: Or(Column("tag", "^", "go"), Term("* *D+")),
+	}
+	for want, q := range data {
+		if actual := q.String(); actual != want {
+			t.Fatalf("q.String failed: actual = %s, want = %s", actual, want)
+		}
+	}
+}
+
+func TestQueryFlags(t *testing.T) {
+	data := []struct {
+		q    Query
+		mode Mode
+		want string
+	}{
+		{Term("a"), Strict, "NONE"},
+		{And(Term("a"), Column("tag", "", "b")), Strict, "ALLOW_COLUMN"},
+		{Not(Term("a")), Strict, "ALLOW_LEADING_NOT"},
+		{Term("a"), Permissive, "ALLOW_PRAGMA|ALLOW_COLUMN|QUERY_NO_SYNTAX_ERROR"},
+	}
+	for _, d := range data {
+		if actual := strings.Join(d.q.Flags(d.mode), "|"); actual != d.want {
+			t.Fatalf("q.Flags failed: query = %s, actual = %s, want = %s", d.q, actual, d.want)
+		}
+	}
+	options := grnci.NewDBSelectOptions()
+	if err := Term("a").Apply(options, Strict); err != nil {
+		t.Fatalf("q.Apply failed: %v", err)
+	}
+	if options.Query != "a" || !reflect.DeepEqual(options.QueryFlags, []string{"NONE"}) {
+		t.Fatalf("q.Apply failed: Query = %s, QueryFlags = %v", options.Query, options.QueryFlags)
+	}
+}
+
+func TestQueryInvalidColumn(t *testing.T) {
+	data := []Query{
+		Column("", "", "a"),
+		Column("title a", "", "a"),
+		Column("title:a OR tag", "", "a"),
+		Column("1st", "", "a"),
+		Column("title", "=", "a"),
+		Column("title", "@ OR x:", "a"),
+		And(Term("a"), Not(Column("tag", "?", "b"))),
+		Boost(Or(Column("tag)", "", "a")), 1),
+	}
+	for _, q := range data {
+		if _, err := Format(q); err == nil {
+			t.Fatalf("Format succeeded: query = %s", q)
+		}
+		if err := q.Apply(grnci.NewDBSelectOptions(), Strict); err == nil {
+			t.Fatalf("q.Apply succeeded: query = %s", q)
+		}
+	}
+	if actual, err := Format(Column("user.name", "^", "a")); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	} else if want := "user.name:^a"; actual != want {
+		t.Fatalf("Format failed: actual = %s, want = %s", actual, want)
+	}
+}
@@ -0,0 +1,22 @@
+package grnci
+
+import "testing"
+
+func TestQuoteQuery(t *testing.T) {
+	data := map[string]string{
+		"groonga":          "groonga",
+		"a OR b":           `a \OR b`,
+		"ORACLE OR":        `ORACLE \OR`,
+		`-a +b ~c`:         `\-a \+b \~c`,
+		`"(x)"`:            `\"\(x\)\"`,
+		`title:@x *D+ a\b`: `title\:@x \*D\+ a\\b`,
+	}
+	for src, want := range data {
+		if actual := QuoteQuery(src); actual != want {
+			t.Fatalf("QuoteQuery failed: actual = %s, want = %s", actual, want)
+		}
+	}
+	if actual, want := QuoteQueryPhrase(`a "b" \c`), `"a \"b\" \\c"`; actual != want {
+		t.Fatalf("QuoteQueryPhrase failed: actual = %s, want = %s", actual, want)
+	}
+}
@@ -0,0 +1,179 @@
+package grnci
+
+import (
+	"math"
+	"reflect"
+)
+
+// Repository is a typed accessor for rows of a table.
+// T must be a struct type associated with the table via grnci tags.
+type Repository[T any] struct {
+	db  *DB
+	tbl string
+	rs  *RowStruct
+	key *ColumnField // _key or _id
+}
+
+// NewRepository returns a new Repository associated with tbl.
+// The key field is the field tagged with _key, or _id if T has no _key.
+func NewRepository[T any](db *DB, tbl string) (*Repository[T], error) {
+	rs, err := GetRowStruct(new(T))
+	if err != nil {
+		return nil, err
+	}
+	key, ok := rs.ColumnsByName["_key"]
+	if !ok {
+		if key, ok = rs.ColumnsByName["_id"]; !ok {
+			return nil, NewError(TypeError, "The row struct must have a _key or _id field.", map[string]interface{}{
+				"table": tbl,
+			})
+		}
+	}
+	return &Repository[T]{
+		db:  db,
+		tbl: tbl,
+		rs:  rs,
+		key: key,
+	}, nil
+}
+
+// Table returns the associated table name.
+func (r *Repository[T]) Table() string {
+	return r.tbl
+}
+
+// keyFilter returns a filter which matches the specified keys.
+func (r *Repository[T]) keyFilter(keys []interface{}) string {
+	if len(keys) == 1 {
+		return r.key.Name + " == " + EncodeJSON(keys[0])
+	}
+	var buf []byte
+	buf = append(buf, "in_values("...)
+	buf = append(buf, r.key.Name...)
+	for _, key := range keys {
+		buf = append(buf, ", "...)
+		buf = AppendJSON(buf, key)
+	}
+	buf = append(buf, ')')
+	return string(buf)
+}
+
+// find executes select and returns the rows and the number of hits.
+func (r *Repository[T]) find(options *DBSelectOptions) ([]T, int, error) {
+	var rows []T
+	n, err := r.db.SelectRows(r.tbl, &rows, options)
+	if err != nil {
+		return nil, n, err
+	}
+	return rows, n, nil
+}
+
+// Get returns the row associated with key.
+// If the row does not exist, Get returns nil.
+func (r *Repository[T]) Get(key interface{}) (*T, error) {
+	options := NewDBSelectOptions()
+	options.Filter = r.keyFilter([]interface{}{key})
+	options.Limit = 1
+	rows, _, err := r.find(options)
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return &rows[0], nil
+}
+
+// GetMany returns the rows associated with keys.
+// Keys without associated rows are ignored and the order is not guaranteed.
+func (r *Repository[T]) GetMany(keys ...interface{}) ([]T, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	options := NewDBSelectOptions()
+	options.Filter = r.keyFilter(keys)
+	options.Limit = len(keys)
+	rows, _, err := r.find(options)
+	return rows, err
+}
+
+// Put loads rows and returns the number of loaded rows.
+func (r *Repository[T]) Put(rows ...T) (int, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	return r.db.LoadRows(r.tbl, rows, nil)
+}
+
+// toID converts an integer of any kind into an ID.
+func toID(key interface{}) (int, bool) {
+	v := reflect.ValueOf(key)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if id := v.Int(); id >= 0 && id <= math.MaxUint32 {
+			return int(id), true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if id := v.Uint(); id <= math.MaxUint32 {
+			return int(id), true
+		}
+	}
+	return 0, false
+}
+
+// Delete deletes the rows associated with keys.
+func (r *Repository[T]) Delete(keys ...interface{}) error {
+	for _, key := range keys {
+		var err error
+		if r.key.Name == "_id" {
+			id, ok := toID(key)
+			if !ok {
+				return NewError(CommandError, "The ID must be a non-negative integer.", map[string]interface{}{
+					"table": r.tbl,
+					"key":   key,
+				})
+			}
+			err = r.db.DeleteByID(r.tbl, id)
+		} else {
+			err = r.db.DeleteByKey(r.tbl, key)
+		}
+		if err != nil {
+			if e, ok := err.(*Error); ok && e.Data != nil {
+				e.Data["key"] = key
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Find executes select and returns the rows and the number of hits.
+// If filter is not empty, it overrides options.Filter.
+// options is not modified.
+func (r *Repository[T]) Find(filter string, options *DBSelectOptions) ([]T, int, error) {
+	if options == nil {
+		options = NewDBSelectOptions()
+	}
+	o := *options
+	if filter != "" {
+		o.Filter = filter
+	}
+	return r.find(&o)
+}
+
+// Count returns the number of rows which satisfy filter.
+// If filter is empty, Count returns the number of all rows.
+func (r *Repository[T]) Count(filter string) (int, error) {
+	options := NewDBSelectOptions()
+	options.Filter = filter
+	options.OutputColumns = []string{r.key.Name}
+	options.Limit = 0
+	_, n, err := r.find(options)
+	return n, err
+}
+
+// Exists returns whether or not the row associated with key exists.
+func (r *Repository[T]) Exists(key interface{}) (bool, error) {
+	n, err := r.Count(r.keyFilter([]interface{}{key}))
+	if err != nil {
+		return false, err
+	}
+	return n != 0, nil
+}
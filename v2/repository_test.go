@@ -0,0 +1,112 @@
+package grnci
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testRepositoryRow struct {
+	Key   string `grnci:"_key"`
+	Value int    `grnci:"value"`
+}
+
+func TestRepository(t *testing.T) {
+	h := newTestHandler(func(cmd *Command, body string) (string, error) {
+		switch cmd.Name() {
+		case "select":
+			if cmd.Params()["limit"] == "0" {
+				return `[[[3],[["_key","ShortText"]]]]`, nil
+			}
+			return `[[[1],[["_key","ShortText"],["value","Int32"]],["a",1]]]`, nil
+		case "load":
+			return "2", nil
+		}
+		return "true", nil
+	})
+	db := NewDB(h)
+	r, err := NewRepository[testRepositoryRow](db, "Tbl")
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	row, err := r.Get("a")
+	if err != nil {
+		t.Fatalf("r.Get failed: %v", err)
+	}
+	if want := (testRepositoryRow{Key: "a", Value: 1}); *row != want {
+		t.Fatalf("r.Get failed: actual = %#v, want = %#v", *row, want)
+	}
+	if _, err := r.GetMany("a", "b"); err != nil {
+		t.Fatalf("r.GetMany failed: %v", err)
+	}
+	if n, err := r.Put(testRepositoryRow{Key: "a"}, testRepositoryRow{Key: "b"}); err != nil || n != 2 {
+		t.Fatalf("r.Put failed: n = %d, err = %v", n, err)
+	}
+	if err := r.Delete("a"); err != nil {
+		t.Fatalf("r.Delete failed: %v", err)
+	}
+	options := NewDBSelectOptions()
+	options.Filter = "value > 0"
+	if _, n, err := r.Find("", options); err != nil || n != 1 {
+		t.Fatalf("r.Find failed: n = %d, err = %v", n, err)
+	}
+	if options.OutputColumns != nil {
+		t.Fatalf("r.Find failed: options is modified: %#v", options)
+	}
+	if n, err := r.Count(""); err != nil || n != 3 {
+		t.Fatalf("r.Count failed: n = %d, err = %v", n, err)
+	}
+	if ok, err := r.Exists("a"); err != nil || !ok {
+		t.Fatalf("r.Exists failed: ok = %v, err = %v", ok, err)
+	}
+	var actual []string
+	for _, cmd := range h.commands {
+		actual = append(actual, cmd.String())
+	}
+	want := []string{
+		"select --cache 'no' --command_version '2' --filter '_key == \"a\"' --limit '1' --output_columns '_key,value' --query '' --table 'Tbl'",
+		"select --cache 'no' --command_version '2' --filter 'in_values(_key, \"a\", \"b\")' --limit '2' --output_columns '_key,value' --query '' --table 'Tbl'",
+		"load --columns '_key,value' --table 'Tbl'",
+		"delete --key 'a' --table 'Tbl'",
+		"select --cache 'no' --command_version '2' --filter 'value > 0' --output_columns '_key,value' --query '' --table 'Tbl'",
+		"select --cache 'no' --command_version '2' --limit '0' --output_columns '_key' --query '' --table 'Tbl'",
+		"select --cache 'no' --command_version '2' --filter '_key == \"a\"' --limit '0' --output_columns '_key' --query '' --table 'Tbl'",
+	}
+	if !reflect.DeepEqual(actual, want) {
+		t.Fatalf("Repository failed: actual = %#v, want = %#v", actual, want)
+	}
+}
+
+type testRepositoryIDRow struct {
+	ID    uint32 `grnci:"_id"`
+	Value int    `grnci:"value"`
+}
+
+func TestRepositoryDeleteByID(t *testing.T) {
+	h := newTestHandler(nil)
+	r, err := NewRepository[testRepositoryIDRow](NewDB(h), "Tbl")
+	if err != nil {
+		t.Fatalf("NewRepository failed: %v", err)
+	}
+	row := testRepositoryIDRow{ID: 3}
+	if err := r.Delete(row.ID, int64(4), uint64(5), 6); err != nil {
+		t.Fatalf("r.Delete failed: %v", err)
+	}
+	var actual []string
+	for _, cmd := range h.commands {
+		actual = append(actual, cmd.String())
+	}
+	want := []string{
+		"delete --id '3' --table 'Tbl'",
+		"delete --id '4' --table 'Tbl'",
+		"delete --id '5' --table 'Tbl'",
+		"delete --id '6' --table 'Tbl'",
+	}
+	if !reflect.DeepEqual(actual, want) {
+		t.Fatalf("r.Delete failed: actual = %#v, want = %#v", actual, want)
+	}
+	for _, key := range []interface{}{-1, "3", 1.5, uint64(1) << 40} {
+		if err := r.Delete(key); err == nil {
+			t.Fatalf("r.Delete wrongly succeeded: key = %#v", key)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package shard
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/groonga/grnci/v2"
+)
+
+// RetentionPolicy specifies shards to be kept.
+type RetentionPolicy struct {
+	Keep      int            // Number of days or months to keep including the current one (unlimited if 0)
+	Unit      Granularity    // Unit of Keep
+	MaxShards int            // Maximum number of shards to keep (unlimited if 0)
+	Location  *time.Location // Time zone of shard names (time.Local if nil)
+}
+
+// NewRetentionPolicy returns the default RetentionPolicy.
+func NewRetentionPolicy() *RetentionPolicy {
+	return &RetentionPolicy{
+		Unit: Day,
+	}
+}
+
+// RetentionOptions stores options for ApplyRetention.
+type RetentionOptions struct {
+	DryRun    bool      // Report shards to be removed without removing them
+	Dependent bool      // --dependent of logical_table_remove
+	Force     bool      // --force of logical_table_remove
+	Now       time.Time // Current time (time.Now() if zero)
+}
+
+// NewRetentionOptions returns the default RetentionOptions.
+func NewRetentionOptions() *RetentionOptions {
+	return &RetentionOptions{}
+}
+
+// RetentionOutcome is the outcome of a shard to be removed.
+type RetentionOutcome struct {
+	Name    string    // Shard name
+	Min     time.Time // Start of the shard range (inclusive)
+	Max     time.Time // End of the shard range (exclusive)
+	Reason  string    // "keep" or "max_shards"
+	Removed bool      // Whether or not the shard is removed
+	Err     error     // Error if logical_table_remove failed
+}
+
+// shardRange is the time range of a shard.
+type shardRange struct {
+	name string
+	min  time.Time
+	max  time.Time
+}
+
+// parseShardName parses a shard name and returns its time range.
+func parseShardName(logicalTable, name string, loc *time.Location) (*shardRange, bool) {
+	if !strings.HasPrefix(name, logicalTable+"_") {
+		return nil, false
+	}
+	suffix := name[len(logicalTable)+1:]
+	switch len(suffix) {
+	case 8:
+		min, err := time.ParseInLocation("20060102", suffix, loc)
+		if err != nil {
+			return nil, false
+		}
+		return &shardRange{name: name, min: min, max: min.AddDate(0, 0, 1)}, true
+	case 6:
+		min, err := time.ParseInLocation("200601", suffix, loc)
+		if err != nil {
+			return nil, false
+		}
+		return &shardRange{name: name, min: min, max: min.AddDate(0, 1, 0)}, true
+	}
+	return nil, false
+}
+
+// cutoff returns the start of the oldest unit to be kept.
+func (p *RetentionPolicy) cutoff(now time.Time) time.Time {
+	y, m, d := now.Date()
+	switch p.Unit {
+	case Month:
+		return time.Date(y, m-time.Month(p.Keep-1), 1, 0, 0, 0, 0, now.Location())
+	default:
+		return time.Date(y, m, d-(p.Keep-1), 0, 0, 0, 0, now.Location())
+	}
+}
+
+// Expired returns the shards to be removed in ascending order.
+// Shards whose names do not follow LOGICAL_YYYYMMDD or LOGICAL_YYYYMM are ignored.
+func Expired(logicalTable string, shards []grnci.DBLogicalShard, policy *RetentionPolicy, now time.Time) []RetentionOutcome {
+	if policy == nil {
+		policy = NewRetentionPolicy()
+	}
+	loc := policy.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	var ranges []*shardRange
+	for _, shard := range shards {
+		if r, ok := parseShardName(logicalTable, shard.Name, loc); ok {
+			ranges = append(ranges, r)
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].min.Before(ranges[j].min)
+	})
+	var outcomes []RetentionOutcome
+	if policy.Keep > 0 {
+		cutoff := policy.cutoff(now.In(loc))
+		for len(ranges) != 0 && !ranges[0].max.After(cutoff) {
+			outcomes = append(outcomes, RetentionOutcome{
+				Name:   ranges[0].name,
+				Min:    ranges[0].min,
+				Max:    ranges[0].max,
+				Reason: "keep",
+			})
+			ranges = ranges[1:]
+		}
+	}
+	if policy.MaxShards > 0 {
+		for len(ranges) > policy.MaxShards {
+			outcomes = append(outcomes, RetentionOutcome{
+				Name:   ranges[0].name,
+				Min:    ranges[0].min,
+				Max:    ranges[0].max,
+				Reason: "max_shards",
+			})
+			ranges = ranges[1:]
+		}
+	}
+	return outcomes
+}
+
+// ApplyRetention removes the shards of logicalTable expired by policy.
+// Each shard is removed by logical_table_remove with its exact range and
+// a failure does not stop the removal of the other shards.
+// The error is not nil only if the shard list is not available.
+func ApplyRetention(db *grnci.DB, logicalTable, shardKey string, policy *RetentionPolicy, options *RetentionOptions) ([]RetentionOutcome, error) {
+	if options == nil {
+		options = NewRetentionOptions()
+	}
+	shards, err := db.LogicalShardList(logicalTable)
+	if err != nil {
+		return nil, err
+	}
+	now := options.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	outcomes := Expired(logicalTable, shards, policy, now)
+	if options.DryRun {
+		return outcomes, nil
+	}
+	for i := range outcomes {
+		o := &outcomes[i]
+		removeOptions := grnci.NewDBLogicalTableRemoveOptions()
+		removeOptions.Min = o.Min
+		removeOptions.MinBorder = true
+		removeOptions.Max = o.Max
+		removeOptions.MaxBorder = false
+		removeOptions.Dependent = options.Dependent
+		removeOptions.Force = options.Force
+		if err := db.LogicalTableRemove(logicalTable, shardKey, removeOptions); err != nil {
+			o.Err = err
+			continue
+		}
+		o.Removed = true
+	}
+	return outcomes, nil
+}
@@ -0,0 +1,71 @@
+package shard
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/groonga/grnci/v2"
+)
+
+func TestExpired(t *testing.T) {
+	shards := []grnci.DBLogicalShard{
+		{Name: "Logs_20170103"},
+		{Name: "Logs_20170101"},
+		{Name: "Logs_201612"},
+		{Name: "Logs_20170102"},
+		{Name: "Logs_20170110"},
+		{Name: "Logs_backup"},
+	}
+	now := time.Date(2017, 1, 10, 12, 0, 0, 0, time.UTC)
+	data := []struct {
+		policy RetentionPolicy
+		want   string
+	}{
+		{RetentionPolicy{Keep: 10, Location: time.UTC}, "Logs_201612:keep"},
+		{RetentionPolicy{Keep: 8, Location: time.UTC}, "Logs_201612:keep,Logs_20170101:keep,Logs_20170102:keep"},
+		{RetentionPolicy{Keep: 1, Unit: Month, Location: time.UTC}, "Logs_201612:keep"},
+		{RetentionPolicy{MaxShards: 2, Location: time.UTC}, "Logs_201612:max_shards,Logs_20170101:max_shards,Logs_20170102:max_shards"},
+		{RetentionPolicy{Keep: 10, MaxShards: 3, Location: time.UTC}, "Logs_201612:keep,Logs_20170101:max_shards"},
+		{RetentionPolicy{Location: time.UTC}, ""},
+	}
+	for _, d := range data {
+		var names []string
+		for _, o := range Expired("Logs", shards, &d.policy, now) {
+			names = append(names, o.Name+":"+o.Reason)
+		}
+		if actual := strings.Join(names, ","); actual != d.want {
+			t.Fatalf("Expired failed: policy = %#v, actual = %s, want = %s", d.policy, actual, d.want)
+		}
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	h := newTestHandler()
+	db := grnci.NewDB(h)
+	policy := NewRetentionPolicy()
+	policy.Keep = 1
+	policy.Location = time.UTC
+	options := NewRetentionOptions()
+	options.Now = time.Date(2017, 1, 3, 0, 0, 0, 0, time.UTC)
+	options.DryRun = true
+	outcomes, err := ApplyRetention(db, "Logs", "timestamp", policy, options)
+	if err != nil {
+		t.Fatalf("ApplyRetention failed: %v", err)
+	}
+	if len(outcomes) != 1 || outcomes[0].Removed || len(testCommands(h)) != 0 {
+		t.Fatalf("ApplyRetention failed: outcomes = %#v, commands = %v", outcomes, testCommands(h))
+	}
+	options.DryRun = false
+	options.Dependent = true
+	if outcomes, err = ApplyRetention(db, "Logs", "timestamp", policy, options); err != nil {
+		t.Fatalf("ApplyRetention failed: %v", err)
+	}
+	if !outcomes[0].Removed {
+		t.Fatalf("ApplyRetention failed: outcomes = %#v", outcomes)
+	}
+	want := "logical_table_remove --dependent 'yes' --force 'no' --logical_table 'Logs' --max '1483315200' --max_border 'exclude' --min '1483228800' --min_border 'include' --shard_key 'timestamp'"
+	if actual := strings.Join(testCommands(h), "\n"); actual != want {
+		t.Fatalf("ApplyRetention failed: actual = %s, want = %s", actual, want)
+	}
+}
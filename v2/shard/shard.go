@@ -0,0 +1,319 @@
+// Package shard provides a router to load records into time-based shards
+// of logical tables.
+//
+// A logical table LOGICAL is a set of tables named LOGICAL_YYYYMMDD (daily)
+// or LOGICAL_YYYYMM (monthly), which are the targets of logical_select,
+// logical_count and logical_range_filter.
+// A Router creates missing shards from a template on demand and
+// routes records to shards by their shard key.
+package shard
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/groonga/grnci/v2"
+	"github.com/groonga/grnci/v2/migrate"
+)
+
+// Granularity is the time range of a shard.
+type Granularity int
+
+const (
+	// Day creates a shard per day (LOGICAL_YYYYMMDD).
+	Day Granularity = iota
+	// Month creates a shard per month (LOGICAL_YYYYMM).
+	Month
+)
+
+// Suffix returns the shard suffix of t, such as "_20170102" and "_201701".
+func (g Granularity) Suffix(t time.Time) string {
+	switch g {
+	case Month:
+		return t.Format("_200601")
+	default:
+		return t.Format("_20060102")
+	}
+}
+
+// RouterOptions stores options for Router.
+type RouterOptions struct {
+	Granularity Granularity    // Time range of a shard
+	Location    *time.Location // Time zone of shard names (time.Local if nil)
+}
+
+// NewRouterOptions returns the default RouterOptions.
+func NewRouterOptions() *RouterOptions {
+	return &RouterOptions{
+		Granularity: Day,
+	}
+}
+
+// Router routes records of a logical table to its shards.
+//
+// The template is a schema whose tables are created per shard.
+// The table named the logical table is the shard itself and
+// other tables, such as lexicons for range indexes, are created with
+// the same suffix. References between template tables are renamed
+// accordingly, and references to other tables are kept as is.
+type Router struct {
+	db           *grnci.DB
+	logicalTable string
+	shardKey     string
+	template     *grnci.DBSchema
+	options      *RouterOptions
+	mutex        sync.Mutex
+	shards       map[string]bool // Known shards (nil if not loaded)
+}
+
+// NewRouter returns a new Router.
+func NewRouter(db *grnci.DB, logicalTable, shardKey string, template *grnci.DBSchema, options *RouterOptions) (*Router, error) {
+	if options == nil {
+		options = NewRouterOptions()
+	}
+	if template == nil {
+		return nil, grnci.NewError(grnci.CommandError, "The template must not be nil.", map[string]interface{}{
+			"logicalTable": logicalTable,
+		})
+	}
+	tbl, ok := template.Tables[logicalTable]
+	if !ok {
+		return nil, grnci.NewError(grnci.CommandError, "The template does not contain the logical table.", map[string]interface{}{
+			"logicalTable": logicalTable,
+		})
+	}
+	col, ok := tbl.Columns[shardKey]
+	if !ok || col.ValueType.Name != "Time" {
+		return nil, grnci.NewError(grnci.CommandError, "The shard key must be a Time column.", map[string]interface{}{
+			"logicalTable": logicalTable,
+			"shardKey":     shardKey,
+		})
+	}
+	return &Router{
+		db:           db,
+		logicalTable: logicalTable,
+		shardKey:     shardKey,
+		template:     template,
+		options:      options,
+	}, nil
+}
+
+// NewRouterFromStruct returns a new Router whose template is derived
+// from the struct tags of v. See migrate.TableFromStruct for details.
+func NewRouterFromStruct(db *grnci.DB, logicalTable, shardKey string, v interface{}, options *RouterOptions) (*Router, error) {
+	template := migrate.NewSchema()
+	if err := migrate.AddStruct(template, logicalTable, v); err != nil {
+		return nil, err
+	}
+	return NewRouter(db, logicalTable, shardKey, template, options)
+}
+
+// ShardName returns the name of the shard for t.
+func (r *Router) ShardName(t time.Time) string {
+	loc := r.options.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	return r.logicalTable + r.options.Granularity.Suffix(t.In(loc))
+}
+
+// loadShards loads the shard list if not loaded.
+// The caller must hold r.mutex.
+func (r *Router) loadShards() error {
+	if r.shards != nil {
+		return nil
+	}
+	shards, err := r.db.LogicalShardList(r.logicalTable)
+	if err != nil {
+		return err
+	}
+	r.shards = make(map[string]bool)
+	for _, shard := range shards {
+		r.shards[shard.Name] = true
+	}
+	return nil
+}
+
+// Shards returns the names of the known shards in ascending order.
+func (r *Router) Shards() ([]string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := r.loadShards(); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(r.shards))
+	for name := range r.shards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Refresh discards the cached shard list.
+// It should be called after shards are removed by other clients.
+func (r *Router) Refresh() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.shards = nil
+}
+
+// shardSchema returns the template tables renamed with suffix.
+func (r *Router) shardSchema(suffix string) map[string]grnci.DBSchemaTable {
+	rename := func(name string) string {
+		if _, ok := r.template.Tables[name]; ok {
+			return name + suffix
+		}
+		return name
+	}
+	tables := make(map[string]grnci.DBSchemaTable, len(r.template.Tables))
+	for name, tbl := range r.template.Tables {
+		tbl.Name = rename(name)
+		if tbl.KeyType != nil {
+			keyType := *tbl.KeyType
+			keyType.Name = rename(keyType.Name)
+			tbl.KeyType = &keyType
+		}
+		if tbl.ValueType != nil {
+			valueType := *tbl.ValueType
+			valueType.Name = rename(valueType.Name)
+			tbl.ValueType = &valueType
+		}
+		columns := make(map[string]grnci.DBSchemaColumn, len(tbl.Columns))
+		for colName, col := range tbl.Columns {
+			col.Table = tbl.Name
+			col.FullName = tbl.Name + "." + colName
+			col.ValueType.Name = rename(col.ValueType.Name)
+			sources := make([]grnci.DBSchemaSource, len(col.Sources))
+			for i, src := range col.Sources {
+				src.Table = rename(src.Table)
+				src.FullName = src.Table + "." + src.Name
+				sources[i] = src
+			}
+			col.Sources = sources
+			columns[colName] = col
+		}
+		tbl.Columns = columns
+		tables[tbl.Name] = tbl
+	}
+	return tables
+}
+
+// createShard creates the shard and its per-shard tables.
+func (r *Router) createShard(name string) error {
+	current, err := r.db.Schema()
+	if err != nil {
+		return err
+	}
+	desired := &grnci.DBSchema{
+		Types:  current.Types,
+		Tables: make(map[string]grnci.DBSchemaTable, len(current.Tables)+len(r.template.Tables)),
+	}
+	for tblName, tbl := range current.Tables {
+		desired.Tables[tblName] = tbl
+	}
+	for tblName, tbl := range r.shardSchema(strings.TrimPrefix(name, r.logicalTable)) {
+		if _, ok := current.Tables[tblName]; !ok {
+			desired.Tables[tblName] = tbl
+		}
+	}
+	plan, err := migrate.Diff(current, desired, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := plan.Apply(r.db); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Shard returns the name of the shard for t and creates the shard if missing.
+func (r *Router) Shard(t time.Time) (string, error) {
+	name := r.ShardName(t)
+	if err := r.ensureShards([]string{name}); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// ensureShards creates the shards in names if they do not exist.
+func (r *Router) ensureShards(names []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := r.loadShards(); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if r.shards[name] {
+			continue
+		}
+		if err := r.createShard(name); err != nil {
+			return err
+		}
+		r.shards[name] = true
+	}
+	return nil
+}
+
+// LoadRows loads rows into the shards associated with their shard keys.
+// rows must be a slice of structs and the shard key must be a time.Time field.
+// Missing shards are created before loading.
+func (r *Router) LoadRows(rows interface{}, options *grnci.DBLoadOptions) (int, error) {
+	rs, err := grnci.GetRowStruct(rows)
+	if err != nil {
+		return 0, err
+	}
+	cf, ok := rs.ColumnsByName[r.shardKey]
+	if !ok {
+		return 0, grnci.NewError(grnci.CommandError, "The shard key has no associated field.", map[string]interface{}{
+			"shardKey": r.shardKey,
+		})
+	}
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return 0, grnci.NewError(grnci.CommandError, "The rows must be a slice.", map[string]interface{}{
+			"type": v.Type().String(),
+		})
+	}
+	// Group rows by shard in order of appearance.
+	var names []string
+	groups := make(map[string]reflect.Value)
+	for i := 0; i < v.Len(); i++ {
+		row := v.Index(i)
+		if row.Kind() == reflect.Ptr {
+			row = row.Elem()
+		}
+		t, ok := row.Field(cf.Index).Interface().(time.Time)
+		if !ok {
+			return 0, grnci.NewError(grnci.TypeError, "The shard key must be time.Time.", map[string]interface{}{
+				"shardKey": r.shardKey,
+			})
+		}
+		name := r.ShardName(t)
+		group, ok := groups[name]
+		if !ok {
+			names = append(names, name)
+			group = reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, 1)
+		}
+		groups[name] = reflect.Append(group, v.Index(i))
+	}
+	if err := r.ensureShards(names); err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, name := range names {
+		var opts *grnci.DBLoadOptions
+		if options != nil {
+			o := *options
+			opts = &o
+		}
+		n, err := r.db.LoadRows(name, groups[name].Interface(), opts)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
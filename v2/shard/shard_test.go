@@ -0,0 +1,97 @@
+package shard
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/groonga/grnci/v2"
+	"github.com/groonga/grnci/v2/dryrun"
+	"github.com/groonga/grnci/v2/migrate"
+)
+
+// newTestHandler returns a dryrun.Handler with one shard of Logs.
+func newTestHandler() *dryrun.Handler {
+	options := dryrun.NewHandlerOptions()
+	options.Fixtures = map[string]string{
+		"logical_shard_list": `[{"name":"Logs_20170101"}]`,
+		"schema":             `{"tables":{"Logs_20170101":{"name":"Logs_20170101","type":"array","columns":{}}}}`,
+	}
+	return dryrun.NewHandler(options)
+}
+
+// testCommands returns the recorded commands except lookups.
+func testCommands(h *dryrun.Handler) []string {
+	var cmds []string
+	for _, entry := range h.Entries() {
+		switch entry.Command.Name() {
+		case "logical_shard_list", "schema":
+			continue
+		}
+		s := entry.Command.String()
+		if entry.Body != nil {
+			s += " " + string(entry.Body)
+		}
+		cmds = append(cmds, s)
+	}
+	return cmds
+}
+
+type Log struct {
+	Timestamp time.Time `grnci:"timestamp"`
+	Message   string    `grnci:"message"`
+}
+
+func TestRouter(t *testing.T) {
+	template := migrate.NewSchema()
+	if err := migrate.AddStruct(template, "Logs", Log{}); err != nil {
+		t.Fatalf("migrate.AddStruct failed: %v", err)
+	}
+	template.Tables["Times"] = grnci.DBSchemaTable{
+		Name:    "Times",
+		Type:    "patricia trie",
+		KeyType: &grnci.DBSchemaKeyType{Name: "Time"},
+	}
+	if err := migrate.AddIndex(template, "Times.logs", "Logs", []string{"timestamp"}, nil); err != nil {
+		t.Fatalf("migrate.AddIndex failed: %v", err)
+	}
+	h := newTestHandler()
+	options := NewRouterOptions()
+	options.Location = time.UTC
+	if _, err := NewRouter(grnci.NewDB(h), "Logs", "timestamp", nil, options); err == nil {
+		t.Fatalf("NewRouter wrongly succeeded")
+	} else if e, ok := err.(*grnci.Error); !ok || e.Code != grnci.CommandError {
+		t.Fatalf("NewRouter failed: %v", err)
+	}
+	r, err := NewRouter(grnci.NewDB(h), "Logs", "timestamp", template, options)
+	if err != nil {
+		t.Fatalf("NewRouter failed: %v", err)
+	}
+	logs := []Log{
+		{time.Date(2017, 1, 1, 10, 0, 0, 0, time.UTC), "a"},
+		{time.Date(2017, 1, 2, 10, 0, 0, 0, time.UTC), "b"},
+		{time.Date(2017, 1, 1, 11, 0, 0, 0, time.UTC), "c"},
+	}
+	if _, err := r.LoadRows(logs, nil); err != nil {
+		t.Fatalf("r.LoadRows failed: %v", err)
+	}
+	want := []string{
+		"table_create --flags 'TABLE_NO_KEY' --name 'Logs_20170102'",
+		"table_create --flags 'TABLE_PAT_KEY' --key_type 'Time' --name 'Times_20170102'",
+		"column_create --flags 'COLUMN_SCALAR' --name 'message' --table 'Logs_20170102' --type 'ShortText'",
+		"column_create --flags 'COLUMN_SCALAR' --name 'timestamp' --table 'Logs_20170102' --type 'Time'",
+		"column_create --flags 'COLUMN_INDEX' --name 'logs' --source 'timestamp' --table 'Times_20170102' --type 'Logs_20170102'",
+		`load --columns 'timestamp,message' --table 'Logs_20170101' [[1483264800,"a"],[1483268400,"c"]]`,
+		`load --columns 'timestamp,message' --table 'Logs_20170102' [[1483351200,"b"]]`,
+	}
+	if actual := strings.Join(testCommands(h), "\n"); actual != strings.Join(want, "\n") {
+		t.Fatalf("r.LoadRows failed: actual = %s, want = %s", actual, strings.Join(want, "\n"))
+	}
+	shards, err := r.Shards()
+	if err != nil {
+		t.Fatalf("r.Shards failed: %v", err)
+	}
+	if actual, want := strings.Join(shards, ","), "Logs_20170101,Logs_20170102"; actual != want {
+		t.Fatalf("r.Shards failed: actual = %s, want = %s", actual, want)
+	}
+}
@@ -0,0 +1,329 @@
+// Package tenant provides a Handler to scope commands to a tenant.
+//
+// Handler wraps another Handler and checks every command before sending it.
+// Commands with --filter, such as select, delete and logical_select, are
+// scoped by a tenant predicate (COLUMN == VALUE), load is checked row by row
+// and scoped by --ifexists, and the other commands are rejected unless they
+// are marked as safe.
+package tenant
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	"github.com/groonga/grnci/v2"
+	"github.com/groonga/grnci/v2/expr"
+)
+
+// Mode specifies how to deal with commands without the tenant predicate.
+type Mode int
+
+const (
+	// Inject adds the tenant predicate to --filter (--ifexists for load).
+	Inject Mode = iota
+	// Require rejects commands whose --filter (--ifexists for load)
+	// does not contain the tenant predicate.
+	Require
+)
+
+// DefaultSafe is the default list of commands that do not read or write records
+// and thus are sent without the tenant predicate.
+var DefaultSafe = []string{
+	"column_list",
+	"normalize",
+	"normalizer_list",
+	"object_exist",
+	"schema",
+	"status",
+	"table_list",
+	"tokenize",
+	"tokenizer_list",
+}
+
+// filterTableKeys maps commands with --filter to their table parameters.
+var filterTableKeys = map[string]string{
+	"delete":               "table",
+	"logical_count":        "logical_table",
+	"logical_range_filter": "logical_table",
+	"logical_select":       "logical_table",
+	"select":               "table",
+}
+
+// HandlerOptions stores options for Handler.
+type HandlerOptions struct {
+	Mode   Mode     // How to deal with commands without the tenant predicate
+	Tables []string // Tables to be scoped (all tables if nil)
+	Safe   []string // Commands to be sent as is
+}
+
+// NewHandlerOptions returns the default HandlerOptions.
+func NewHandlerOptions() *HandlerOptions {
+	return &HandlerOptions{
+		Mode: Inject,
+		Safe: DefaultSafe,
+	}
+}
+
+// Handler is a Handler to scope commands to a tenant.
+type Handler struct {
+	h         grnci.Handler
+	column    string
+	value     interface{}
+	predicate expr.Expr
+	options   *HandlerOptions
+	tables    map[string]bool
+	safe      map[string]bool
+}
+
+// NewHandler returns a new Handler to scope commands sent via h
+// to records whose column is equal to value.
+func NewHandler(h grnci.Handler, column string, value interface{}, options *HandlerOptions) (*Handler, error) {
+	if options == nil {
+		options = NewHandlerOptions()
+	}
+	predicate := expr.Equal(column, value)
+	if _, err := expr.Format(predicate); err != nil {
+		return nil, err
+	}
+	handler := &Handler{
+		h:         h,
+		column:    column,
+		value:     value,
+		predicate: predicate,
+		options:   options,
+		safe:      make(map[string]bool),
+	}
+	if options.Tables != nil {
+		handler.tables = make(map[string]bool)
+		for _, tbl := range options.Tables {
+			handler.tables[tbl] = true
+		}
+	}
+	for _, name := range options.Safe {
+		handler.safe[name] = true
+	}
+	return handler, nil
+}
+
+// Exec parses cmd, checks the parsed command, sends it and returns the response.
+// It is the caller's responsibility to close the response.
+func (h *Handler) Exec(cmd string, body io.Reader) (grnci.Response, error) {
+	command, err := grnci.ParseCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	command.SetBody(body)
+	return h.Query(command)
+}
+
+// Invoke assembles name and params into a command,
+// checks the command, sends it and returns the response.
+// It is the caller's responsibility to close the response.
+func (h *Handler) Invoke(name string, params map[string]interface{}, body io.Reader) (grnci.Response, error) {
+	cmd, err := grnci.NewCommand(name, params)
+	if err != nil {
+		return nil, err
+	}
+	cmd.SetBody(body)
+	return h.Query(cmd)
+}
+
+// Query checks cmd, sends it and returns the response.
+// It is the caller's responsibility to close the response.
+func (h *Handler) Query(cmd *grnci.Command) (grnci.Response, error) {
+	if err := h.Scope(cmd); err != nil {
+		return nil, err
+	}
+	return h.h.Query(cmd)
+}
+
+// Close closes the underlying handler.
+func (h *Handler) Close() error {
+	return h.h.Close()
+}
+
+// newError returns an error of cmd.
+func newError(cmd *grnci.Command, msg string, data map[string]interface{}) *grnci.Error {
+	err := grnci.NewError(grnci.CommandError, msg, data)
+	err.Data["command"] = cmd.Name()
+	return err
+}
+
+// scoped returns whether or not tbl is scoped.
+func (h *Handler) scoped(tbl string) bool {
+	return h.tables == nil || h.tables[tbl]
+}
+
+// Scope checks cmd and modifies its parameters and body to scope it to the tenant.
+// Commands that cannot be scoped are rejected unless they are marked as safe.
+func (h *Handler) Scope(cmd *grnci.Command) error {
+	name := cmd.Name()
+	params := cmd.Params()
+	if key, ok := filterTableKeys[name]; ok {
+		if !h.scoped(params[key]) {
+			return nil
+		}
+		if strings.Contains(params["query_flags"], "ALLOW_UPDATE") {
+			return newError(cmd, "ALLOW_UPDATE is not allowed for tenants.", map[string]interface{}{
+				"query_flags": params["query_flags"],
+			})
+		}
+		if name == "delete" {
+			if _, ok := params["key"]; ok {
+				return newError(cmd, "delete --key is not allowed for tenants.", nil)
+			}
+			if _, ok := params["id"]; ok {
+				return newError(cmd, "delete --id is not allowed for tenants.", nil)
+			}
+		}
+		return h.scopeExpr(cmd, "filter")
+	}
+	if name == "load" {
+		if !h.scoped(params["table"]) {
+			return nil
+		}
+		return h.checkLoad(cmd)
+	}
+	if h.safe[name] {
+		return nil
+	}
+	return newError(cmd, "The command is not allowed for tenants.", nil)
+}
+
+// hasPredicate returns whether or not the top-level conjunction of e
+// contains the tenant predicate.
+func (h *Handler) hasPredicate(e expr.Expr) bool {
+	b, ok := e.(*expr.Binary)
+	if !ok {
+		return false
+	}
+	switch b.Op {
+	case "&&":
+		return h.hasPredicate(b.X) || h.hasPredicate(b.Y)
+	case "&!":
+		return h.hasPredicate(b.X)
+	case "==":
+		return b.String() == h.predicate.String()
+	}
+	return false
+}
+
+// scopeExpr adds the tenant predicate to the expression parameter key
+// (--filter or --ifexists) or checks it.
+func (h *Handler) scopeExpr(cmd *grnci.Command, key string) error {
+	filter, ok := cmd.Params()[key]
+	if !ok || strings.TrimSpace(filter) == "" {
+		if h.options.Mode == Require {
+			return newError(cmd, "The command requires the tenant predicate.", map[string]interface{}{
+				"key":       key,
+				"predicate": h.predicate.String(),
+			})
+		}
+		return cmd.SetParam(key, h.predicate.String())
+	}
+	e, err := expr.Parse(filter)
+	if err != nil {
+		if e, ok := err.(*grnci.Error); ok {
+			e.Data["command"] = cmd.Name()
+		}
+		return err
+	}
+	if h.hasPredicate(e) {
+		return nil
+	}
+	if h.options.Mode == Require {
+		return newError(cmd, "The expression does not contain the tenant predicate.", map[string]interface{}{
+			"key":       key,
+			"value":     filter,
+			"predicate": h.predicate.String(),
+		})
+	}
+	return cmd.SetParam(key, expr.And(h.predicate, e).String())
+}
+
+// checkLoad checks that every row of load has the tenant value
+// and scopes updates of existing records by --ifexists.
+func (h *Handler) checkLoad(cmd *grnci.Command) error {
+	params := cmd.Params()
+	if _, ok := params["each"]; ok {
+		return newError(cmd, "load --each is not allowed for tenants.", nil)
+	}
+	var data []byte
+	if values, ok := params["values"]; ok {
+		data = []byte(values)
+	} else if cmd.Body() != nil {
+		var err error
+		if data, err = ioutil.ReadAll(cmd.Body()); err != nil {
+			return grnci.NewError(grnci.InputError, "ioutil.ReadAll failed.", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		cmd.SetBody(bytes.NewReader(data))
+	}
+	var want interface{}
+	if err := json.Unmarshal(grnci.AppendJSONValue(nil, reflect.ValueOf(h.value)), &want); err != nil {
+		return grnci.NewError(grnci.InputError, "json.Unmarshal failed.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	var rows []json.RawMessage
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return newError(cmd, "The values must be a JSON array.", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	var columns []string
+	if s, ok := params["columns"]; ok {
+		for _, col := range strings.Split(s, ",") {
+			columns = append(columns, strings.TrimSpace(col))
+		}
+	}
+	for i, row := range rows {
+		var value interface{}
+		found := false
+		row = bytes.TrimSpace(row)
+		if len(row) != 0 && row[0] == '{' {
+			var obj map[string]interface{}
+			if err := json.Unmarshal(row, &obj); err != nil {
+				return newError(cmd, "The row is invalid.", map[string]interface{}{
+					"row":   i,
+					"error": err.Error(),
+				})
+			}
+			value, found = obj[h.column]
+		} else {
+			var arr []interface{}
+			if err := json.Unmarshal(row, &arr); err != nil {
+				return newError(cmd, "The row is invalid.", map[string]interface{}{
+					"row":   i,
+					"error": err.Error(),
+				})
+			}
+			if columns == nil {
+				// The first row is the header if --columns is not specified.
+				for _, col := range arr {
+					s, _ := col.(string)
+					columns = append(columns, s)
+				}
+				continue
+			}
+			for j, col := range columns {
+				if col == h.column && j < len(arr) {
+					value, found = arr[j], true
+				}
+			}
+		}
+		if !found || !reflect.DeepEqual(value, want) {
+			return newError(cmd, "The row does not belong to the tenant.", map[string]interface{}{
+				"row":    i,
+				"column": h.column,
+				"value":  value,
+			})
+		}
+	}
+	return h.scopeExpr(cmd, "ifexists")
+}
@@ -0,0 +1,189 @@
+package tenant
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/groonga/grnci/v2"
+	"github.com/groonga/grnci/v2/dryrun"
+)
+
+func TestHandlerInject(t *testing.T) {
+	th := dryrun.NewHandler(nil)
+	h, err := NewHandler(th, "tenant_id", 10, nil)
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+	data := map[string]string{
+		`select Posts`:           `tenant_id == 10`,
+		`select Posts --query x`: `tenant_id == 10`,
+		`select Posts --filter 'title @ "x" || body @ "x"'`:      `tenant_id == 10 && (title @ "x" || body @ "x")`,
+		`select Posts --filter 'tenant_id == 10 && title @ "x"'`: `tenant_id == 10 && title @ "x"`,
+		`logical_select Logs timestamp --filter 'true'`:          `tenant_id == 10 && true`,
+		`delete Posts --filter '_id > 3'`:                        `tenant_id == 10 && _id > 3`,
+	}
+	for cmd, want := range data {
+		if _, err := h.Exec(cmd, nil); err != nil {
+			t.Fatalf("h.Exec failed: cmd = %s, err = %v", cmd, err)
+		}
+		entries := th.Entries()
+		if actual := entries[len(entries)-1].Command.Params()["filter"]; actual != want {
+			t.Fatalf("h.Exec failed: actual = %s, want = %s", actual, want)
+		}
+	}
+	invalid := []string{
+		`dump`,
+		`table_remove Posts`,
+		`range_filter --table Posts --column created_at`,
+		`table_create Posts2`,
+		`no_such_command`,
+		`delete Posts --key x`,
+		`select Posts --filter 'title @ "x") || (true'`,
+	}
+	for _, cmd := range invalid {
+		if _, err := h.Exec(cmd, nil); err == nil {
+			t.Fatalf("h.Exec succeeded: cmd = %s", cmd)
+		}
+	}
+}
+
+func TestHandlerRequire(t *testing.T) {
+	th := dryrun.NewHandler(nil)
+	options := NewHandlerOptions()
+	options.Mode = Require
+	options.Tables = []string{"Posts"}
+	h, err := NewHandler(th, "tenant_id", "a", options)
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+	valid := []string{
+		`select Posts --filter 'x > 1 && tenant_id == "a"'`,
+		`select Users`,
+		`status`,
+	}
+	for _, cmd := range valid {
+		if _, err := h.Exec(cmd, nil); err != nil {
+			t.Fatalf("h.Exec failed: cmd = %s, err = %v", cmd, err)
+		}
+	}
+	invalid := []string{
+		`select Posts`,
+		`select Posts --filter 'tenant_id == "b"'`,
+		`select Posts --filter 'x > 1 || tenant_id == "a"'`,
+	}
+	for _, cmd := range invalid {
+		if _, err := h.Exec(cmd, nil); err == nil {
+			t.Fatalf("h.Exec succeeded: cmd = %s", cmd)
+		}
+	}
+}
+
+func TestHandlerLoad(t *testing.T) {
+	th := dryrun.NewHandler(nil)
+	h, err := NewHandler(th, "tenant_id", 10, nil)
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+	valid := []string{
+		`[{"_key":"a","tenant_id":10}]`,
+		`[["_key","tenant_id"],["a",10],["b",10]]`,
+	}
+	for _, body := range valid {
+		if _, err := h.Exec("load --table Posts", strings.NewReader(body)); err != nil {
+			t.Fatalf("h.Exec failed: body = %s, err = %v", body, err)
+		}
+	}
+	if _, err := h.Exec("load --table Posts --columns '_key, tenant_id' --values '[[\"a\",10]]'", nil); err != nil {
+		t.Fatalf("h.Exec failed: %v", err)
+	}
+	invalid := []string{
+		`[{"_key":"a","tenant_id":11}]`,
+		`[{"_key":"a"}]`,
+		`[["_key","tenant_id"],["a",10],["b",11]]`,
+		`{}`,
+	}
+	for _, body := range invalid {
+		if _, err := h.Exec("load --table Posts", strings.NewReader(body)); err == nil {
+			t.Fatalf("h.Exec succeeded: body = %s", body)
+		}
+	}
+}
+
+func TestHandlerLoadScope(t *testing.T) {
+	th := dryrun.NewHandler(nil)
+	h, err := NewHandler(th, "tenant_id", 10, nil)
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+	body := `[{"_key":"a","tenant_id":10}]`
+	data := map[string]string{
+		`load --table Posts`:                    `tenant_id == 10`,
+		`load --table Posts --ifexists 'x > 1'`: `tenant_id == 10 && x > 1`,
+	}
+	for cmd, want := range data {
+		if _, err := h.Exec(cmd, strings.NewReader(body)); err != nil {
+			t.Fatalf("h.Exec failed: cmd = %s, err = %v", cmd, err)
+		}
+		entries := th.Entries()
+		if actual := entries[len(entries)-1].Command.Params()["ifexists"]; actual != want {
+			t.Fatalf("h.Exec failed: actual = %s, want = %s", actual, want)
+		}
+	}
+	invalid := []string{
+		`load --table Posts --each 'tenant_id = 2'`,
+		`select Posts --query 'tenant_id:=2' --query_flags ALLOW_PRAGMA|ALLOW_UPDATE`,
+		`select Posts --query 'tenant_id:=2' --query_flags ALLOW_UPDATE`,
+	}
+	for _, cmd := range invalid {
+		if _, err := h.Exec(cmd, strings.NewReader(body)); err == nil {
+			t.Fatalf("h.Exec succeeded: cmd = %s", cmd)
+		}
+	}
+
+	options := NewHandlerOptions()
+	options.Mode = Require
+	if h, err = NewHandler(th, "tenant_id", 10, options); err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+	if _, err := h.Exec(`load --table Posts --ifexists 'tenant_id == 10'`, strings.NewReader(body)); err != nil {
+		t.Fatalf("h.Exec failed: %v", err)
+	}
+	invalid = []string{
+		`load --table Posts`,
+		`load --table Posts --ifexists 'tenant_id == 11'`,
+	}
+	for _, cmd := range invalid {
+		if _, err := h.Exec(cmd, strings.NewReader(body)); err == nil {
+			t.Fatalf("h.Exec succeeded: cmd = %s", cmd)
+		}
+	}
+}
+
+func TestHandlerAllowlist(t *testing.T) {
+	th := dryrun.NewHandler(nil)
+	options := NewHandlerOptions()
+	options.Safe = []string{"status"}
+	h, err := NewHandler(th, "tenant_id", 10, options)
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+	if _, err := h.Exec("status", nil); err != nil {
+		t.Fatalf("h.Exec failed: %v", err)
+	}
+	invalid := []string{
+		`range_filter --table Posts --column created_at`,
+		`suggest --types complete --table item_query --column kana --query x`,
+		`column_create Posts extra COLUMN_SCALAR Int32`,
+		`plugin_register functions/string`,
+		`table_list`,
+	}
+	for _, cmd := range invalid {
+		_, err := h.Exec(cmd, nil)
+		if e, ok := err.(*grnci.Error); !ok || e.Message != "The command is not allowed for tenants." {
+			t.Fatalf("h.Exec failed: cmd = %s, err = %v", cmd, err)
+		}
+	}
+	if _, err := h.Exec("no_such_command", nil); err == nil {
+		t.Fatalf("h.Exec succeeded: cmd = no_such_command")
+	}
+}
@@ -44,18 +44,25 @@ type ColumnField struct {
 // checkTableName checks if s is valid as a table name.
 func checkTableName(s string) error {
 	switch s {
-	case "":
-		return NewError(TypeError, "A table name must not be empty.", map[string]interface{}{
-			"name": s,
-		})
 	case "Bool", "Int8", "Int16", "Int32", "Int64", "UInt8", "UInt16", "UInt32", "UInt64",
 		"Float", "ShortText", "Text", "LongText", "Time", "WGS84GeoPoint", "TokyoGeoPoint":
 		return NewError(TypeError, "The name specifies a built-in type and not available as a table name.", map[string]interface{}{
 			"name": s,
 		})
 	}
+	return checkName("table", s)
+}
+
+// checkName checks if s is valid as a name of a table or a column.
+// A name must consist of [0-9A-Za-z_#@-] and must not start with '_'.
+func checkName(kind, s string) error {
+	if s == "" {
+		return NewError(TypeError, "A "+kind+" name must not be empty.", map[string]interface{}{
+			"name": s,
+		})
+	}
 	if s[0] == '_' {
-		return NewError(TypeError, "A table name must not start with '_'.", map[string]interface{}{
+		return NewError(TypeError, "A "+kind+" name must not start with '_'.", map[string]interface{}{
 			"name": s,
 		})
 	}
@@ -64,9 +71,9 @@ func checkTableName(s string) error {
 		case c >= '0' && c <= '9':
 		case c >= 'A' && c <= 'Z':
 		case c >= 'a' && c <= 'z':
-		case c == '_':
+		case c == '_', c == '#', c == '@', c == '-':
 		default:
-			return NewError(TypeError, "A table name must consist of [0-9A-Za-z_].", map[string]interface{}{
+			return NewError(TypeError, "A "+kind+" name must consist of [0-9A-Za-z_#@-].", map[string]interface{}{
 				"name": s,
 			})
 		}
@@ -74,6 +81,11 @@ func checkTableName(s string) error {
 	return nil
 }
 
+// checkColumnName checks if s is valid as a column name.
+func checkColumnName(s string) error {
+	return checkName("column", s)
+}
+
 // parseIDOptions parses options of _id.
 func (cf *ColumnField) parseIDOptions(options []string) error {
 	if len(options) > 1 {
@@ -285,6 +297,36 @@ func (cf *ColumnField) parseScoreOptions(options []string) error {
 	return nil
 }
 
+// parseCalcOptions parses options of _nsubrecs, _max, _min, _sum and _avg.
+func (cf *ColumnField) parseCalcOptions(options []string) error {
+	if len(options) > 1 {
+		return NewError(TypeError, "The tag must not contain more than one option.", map[string]interface{}{
+			"name":    cf.Name,
+			"options": options,
+		})
+	}
+	if len(options) > 0 {
+		cf.Type = options[0]
+	}
+	switch cf.Type {
+	case "":
+		switch cf.Name {
+		case "_nsubrecs":
+			cf.Type = "Int32"
+		case "_avg":
+			cf.Type = "Float"
+		default:
+			cf.Type = "Int64"
+		}
+	case "Int32", "Int64", "Float":
+	default:
+		return NewError(TypeError, "The type is not supported as "+cf.Name+".", map[string]interface{}{
+			"type": cf.Type,
+		})
+	}
+	return nil
+}
+
 // detectColumnType detects cf.Type from cf.Field.Type.
 func (cf *ColumnField) detectColumnType() error {
 	typ := cf.Field.Type
@@ -440,6 +482,8 @@ func (cf *ColumnField) parseOptions(options []string) error {
 		return cf.parseValueOptions(options)
 	case "_score":
 		return cf.parseScoreOptions(options)
+	case "_nsubrecs", "_max", "_min", "_sum", "_avg":
+		return cf.parseCalcOptions(options)
 	default:
 		return cf.parseColumnOptions(options)
 	}
@@ -0,0 +1,395 @@
+// Package validate provides a Validator to check commands against the schema.
+//
+// The Validator checks table and column references of commands, such as
+// --table, --output_columns, --sort_keys and --match_columns of select,
+// so that typos are rejected locally before the commands reach the server.
+// Handler wraps another Handler and rejects invalid commands.
+package validate
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/groonga/grnci/v2"
+	"github.com/groonga/grnci/v2/expr"
+)
+
+// newError returns an error of cmd.
+func newError(cmd *grnci.Command, msg string, data map[string]interface{}) *grnci.Error {
+	err := grnci.NewError(grnci.CommandError, msg, data)
+	err.Data["command"] = cmd.Name()
+	return err
+}
+
+// splitList splits s by sep outside of parentheses, brackets and quotes.
+func splitList(s, sep string) []string {
+	var items []string
+	depth := 0
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case '"', '\'':
+			for i++; i < len(s) && s[i] != c; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+		default:
+			if depth == 0 && strings.HasPrefix(s[i:], sep) {
+				items = append(items, s[start:i])
+				i += len(sep) - 1
+				start = i + 1
+			}
+		}
+	}
+	return append(items, s[start:])
+}
+
+// isPath returns whether or not s is a column path, such as user.name.
+func isPath(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+		case c >= 'A' && c <= 'Z':
+		case c >= 'a' && c <= 'z':
+		case c == '_' || c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// checker checks references of a command.
+type checker struct {
+	schema  *grnci.DBSchema
+	cmd     *grnci.Command
+	tbl     string
+	dynamic map[string]bool // Dynamic columns (--columns[NAME])
+}
+
+// checkTable checks that tbl exists.
+func (c *checker) checkTable(key, tbl string) error {
+	if _, ok := c.schema.Tables[tbl]; !ok {
+		return newError(c.cmd, "The table does not exist.", map[string]interface{}{
+			"key":   key,
+			"table": tbl,
+		})
+	}
+	return nil
+}
+
+// checkPath checks that the column path exists.
+// If absolute is true, Table.column is also accepted.
+func (c *checker) checkPath(key, path string, absolute bool) error {
+	if c.dynamic[strings.SplitN(path, ".", 2)[0]] {
+		return nil
+	}
+	err := expr.ValidateColumn(c.schema, c.tbl, path)
+	if err == nil {
+		return nil
+	}
+	if absolute {
+		if i := strings.IndexByte(path, '.'); i != -1 {
+			if _, ok := c.schema.Tables[path[:i]]; ok {
+				if expr.ValidateColumn(c.schema, path[:i], path[i+1:]) == nil {
+					return nil
+				}
+			}
+		}
+	}
+	if e, ok := err.(*grnci.Error); ok {
+		e.Data["command"] = c.cmd.Name()
+		e.Data["key"] = key
+	}
+	return err
+}
+
+// checkItem checks the column references of an item, such as a column path,
+// an expression or a function call.
+// Items which cannot be parsed are ignored.
+func (c *checker) checkItem(key, item string, absolute bool) error {
+	item = strings.TrimSpace(item)
+	switch {
+	case item == "" || item == "*":
+		return nil
+	case isPath(item):
+		return c.checkPath(key, item, absolute)
+	}
+	e, err := expr.Parse(item)
+	if err != nil {
+		return nil
+	}
+	for _, path := range expr.Columns(e) {
+		if err := c.checkPath(key, path, absolute); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkList checks the items of a parameter.
+func (c *checker) checkList(key, sep string, absolute bool) error {
+	value, ok := c.cmd.Params()[key]
+	if !ok {
+		return nil
+	}
+	for _, item := range splitList(value, sep) {
+		if key == "sort_keys" || key == "sortby" {
+			item = strings.TrimPrefix(strings.TrimSpace(item), "-")
+		}
+		if err := c.checkItem(key, item, absolute); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSelect checks the parameters of select-like commands.
+func (c *checker) checkSelect() error {
+	for k := range c.cmd.Params() {
+		if strings.HasPrefix(k, "columns[") {
+			if i := strings.IndexByte(k, ']'); i != -1 {
+				c.dynamic[k[len("columns["):i]] = true
+			}
+		}
+	}
+	lists := []struct {
+		key      string
+		sep      string
+		absolute bool
+	}{
+		{"output_columns", ",", false},
+		{"sort_keys", ",", false},
+		{"sortby", ",", false},
+		{"match_columns", "||", true},
+		{"drilldown", ",", false},
+	}
+	for _, list := range lists {
+		if err := c.checkList(list.key, list.sep, list.absolute); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shards returns the shards of logicalTable in name order.
+func shards(schema *grnci.DBSchema, logicalTable string) []string {
+	var names []string
+	for name := range schema.Tables {
+		if !strings.HasPrefix(name, logicalTable+"_") {
+			continue
+		}
+		suffix := name[len(logicalTable)+1:]
+		if len(suffix) != 6 && len(suffix) != 8 {
+			continue
+		}
+		if strings.Trim(suffix, "0123456789") != "" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// inspected is the set of commands checked by Command.
+var inspected = map[string]bool{
+	"select":               true,
+	"logical_count":        true,
+	"logical_range_filter": true,
+	"logical_select":       true,
+	"load":                 true,
+	"column_list":          true,
+	"delete":               true,
+	"column_remove":        true,
+	"column_rename":        true,
+}
+
+// Command checks the table and column references of cmd against schema.
+// Commands which do not refer to tables are always valid.
+func Command(schema *grnci.DBSchema, cmd *grnci.Command) error {
+	params := cmd.Params()
+	c := &checker{
+		schema:  schema,
+		cmd:     cmd,
+		dynamic: make(map[string]bool),
+	}
+	switch cmd.Name() {
+	case "select":
+		c.tbl = params["table"]
+		if err := c.checkTable("table", c.tbl); err != nil {
+			return err
+		}
+		return c.checkSelect()
+	case "logical_count", "logical_range_filter", "logical_select":
+		names := shards(schema, params["logical_table"])
+		if len(names) == 0 {
+			return newError(cmd, "The logical table has no shards.", map[string]interface{}{
+				"key":           "logical_table",
+				"logical_table": params["logical_table"],
+			})
+		}
+		c.tbl = names[0]
+		if err := c.checkPath("shard_key", params["shard_key"], false); err != nil {
+			return err
+		}
+		return c.checkSelect()
+	case "load":
+		c.tbl = params["table"]
+		if err := c.checkTable("table", c.tbl); err != nil {
+			return err
+		}
+		return c.checkList("columns", ",", false)
+	case "column_list", "delete":
+		return c.checkTable("table", params["table"])
+	case "column_remove", "column_rename":
+		c.tbl = params["table"]
+		if err := c.checkTable("table", c.tbl); err != nil {
+			return err
+		}
+		return c.checkPath("name", params["name"], false)
+	}
+	return nil
+}
+
+// Validator checks commands against the schema of a DB.
+type Validator struct {
+	db     *grnci.DB
+	schema *grnci.DBSchema
+	mutex  sync.Mutex
+}
+
+// NewValidator returns a new Validator which reads the schema of db on demand.
+func NewValidator(db *grnci.DB) *Validator {
+	return &Validator{db: db}
+}
+
+// NewValidatorWithSchema returns a new Validator with a fixed schema.
+func NewValidatorWithSchema(schema *grnci.DBSchema) *Validator {
+	return &Validator{schema: schema}
+}
+
+// Schema returns the schema.
+// The schema is read once and cached until Refresh is called.
+func (v *Validator) Schema() (*grnci.DBSchema, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if v.schema != nil {
+		return v.schema, nil
+	}
+	schema, err := v.db.Schema()
+	if err != nil {
+		return nil, err
+	}
+	v.schema = schema
+	return schema, nil
+}
+
+// Refresh discards the cached schema.
+// If the Validator has a fixed schema, Refresh does nothing.
+func (v *Validator) Refresh() {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if v.db != nil {
+		v.schema = nil
+	}
+}
+
+// Validate checks the table and column references of cmd.
+func (v *Validator) Validate(cmd *grnci.Command) error {
+	if !inspected[cmd.Name()] {
+		return nil
+	}
+	schema, err := v.Schema()
+	if err != nil {
+		return err
+	}
+	return Command(schema, cmd)
+}
+
+// Handler is a Handler to reject invalid commands locally.
+type Handler struct {
+	h grnci.Handler
+	v *Validator
+}
+
+// NewHandler returns a new Handler to validate commands sent via h.
+// If v is nil, the schema is read via h.
+func NewHandler(h grnci.Handler, v *Validator) *Handler {
+	if v == nil {
+		v = NewValidator(grnci.NewDB(h))
+	}
+	return &Handler{
+		h: h,
+		v: v,
+	}
+}
+
+// Validator returns the underlying Validator.
+func (h *Handler) Validator() *Validator {
+	return h.v
+}
+
+// Exec parses cmd, validates the parsed command, sends it and returns the response.
+// It is the caller's responsibility to close the response.
+func (h *Handler) Exec(cmd string, body io.Reader) (grnci.Response, error) {
+	command, err := grnci.ParseCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	command.SetBody(body)
+	return h.Query(command)
+}
+
+// Invoke assembles name and params into a command,
+// validates the command, sends it and returns the response.
+// It is the caller's responsibility to close the response.
+func (h *Handler) Invoke(name string, params map[string]interface{}, body io.Reader) (grnci.Response, error) {
+	cmd, err := grnci.NewCommand(name, params)
+	if err != nil {
+		return nil, err
+	}
+	cmd.SetBody(body)
+	return h.Query(cmd)
+}
+
+// Query validates cmd, sends it and returns the response.
+// The cached schema is discarded after a command which may change the schema.
+// It is the caller's responsibility to close the response.
+func (h *Handler) Query(cmd *grnci.Command) (grnci.Response, error) {
+	if err := h.v.Validate(cmd); err != nil {
+		return nil, err
+	}
+	resp, err := h.h.Query(cmd)
+	if changesSchema(cmd.Name()) {
+		h.v.Refresh()
+	}
+	return resp, err
+}
+
+// Close closes the underlying handler.
+func (h *Handler) Close() error {
+	return h.h.Close()
+}
+
+// changesSchema returns whether or not the command may change the schema.
+func changesSchema(name string) bool {
+	switch name {
+	case "column_create", "column_remove", "column_rename",
+		"table_create", "table_remove", "table_rename",
+		"logical_table_remove", "object_remove",
+		"plugin_register", "plugin_unregister", "register":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package validate
+
+import (
+	"testing"
+
+	"github.com/groonga/grnci/v2/dryrun"
+)
+
+const testSchema = `{"tables":{
+"Users":{"name":"Users","type":"hash table","key_type":{"name":"ShortText","type":"type"},
+ "columns":{"name":{"name":"name","value_type":{"name":"ShortText","type":"type"}}}},
+"Posts":{"name":"Posts","type":"hash table","key_type":{"name":"ShortText","type":"type"},
+ "columns":{"title":{"name":"title","value_type":{"name":"ShortText","type":"type"}},
+  "user":{"name":"user","value_type":{"name":"Users","type":"reference"}}}},
+"Terms":{"name":"Terms","type":"patricia trie","key_type":{"name":"ShortText","type":"type"},
+ "columns":{"posts_title":{"name":"posts_title","value_type":{"name":"Posts","type":"reference"}}}},
+"Logs_20170101":{"name":"Logs_20170101","type":"array",
+ "columns":{"timestamp":{"name":"timestamp","value_type":{"name":"Time","type":"type"}}}}
+}}`
+
+func TestHandler(t *testing.T) {
+	thOptions := dryrun.NewHandlerOptions()
+	thOptions.Fixtures = map[string]string{"schema": testSchema}
+	th := dryrun.NewHandler(thOptions)
+	h := NewHandler(th, nil)
+	valid := []string{
+		`select Posts --output_columns '_key,_score,title,user.name,snippet_html(title)'`,
+		`select Posts --sort_keys '-_score,user.name' --match_columns 'title * 10 || Terms.posts_title'`,
+		`select Posts --columns[x].stage initial --columns[x].type Int32 --columns[x].value 1 --output_columns 'x,_nsubrecs'`,
+		`select Posts --drilldown user --output_columns '*'`,
+		`logical_select Logs timestamp --output_columns timestamp`,
+		`load --table Posts --columns '_key,title' --values '[["a","x"]]'`,
+		`column_remove Posts title`,
+		`status`,
+	}
+	for _, cmd := range valid {
+		if _, err := h.Exec(cmd, nil); err != nil {
+			t.Fatalf("h.Exec failed: cmd = %s, err = %v", cmd, err)
+		}
+	}
+	invalid := []string{
+		`select Post`,
+		`select Posts --output_columns '_key,titel'`,
+		`select Posts --output_columns 'snippet_html(titel)'`,
+		`select Posts --sort_keys '-user.nmae'`,
+		`select Posts --match_columns 'title || body'`,
+		`logical_select Log timestamp`,
+		`logical_select Logs time`,
+		`load --table Posts --columns '_key,body'`,
+		`column_rename Posts body text`,
+	}
+	for _, cmd := range invalid {
+		if _, err := h.Exec(cmd, nil); err == nil {
+			t.Fatalf("h.Exec wrongly succeeded: cmd = %s", cmd)
+		}
+	}
+	var nSchema, n int
+	for _, entry := range th.Entries() {
+		if entry.Command.Name() == "schema" {
+			nSchema++
+		} else {
+			n++
+		}
+	}
+	if nSchema != 2 || n != len(valid) {
+		t.Fatalf("h.Exec failed: nSchema = %d, n = %d, want = %d, %d", nSchema, n, 2, len(valid))
+	}
+}
+
+func TestHandlerSchemaError(t *testing.T) {
+	thOptions := dryrun.NewHandlerOptions()
+	thOptions.Fixtures = map[string]string{"schema": "broken"}
+	th := dryrun.NewHandler(thOptions)
+	h := NewHandler(th, nil)
+	for _, cmd := range []string{`status`, `table_list`} {
+		if _, err := h.Exec(cmd, nil); err != nil {
+			t.Fatalf("h.Exec failed: cmd = %s, err = %v", cmd, err)
+		}
+	}
+	if _, err := h.Exec(`select Posts`, nil); err == nil {
+		t.Fatalf("h.Exec wrongly succeeded: cmd = select Posts")
+	}
+	for _, entry := range th.Entries() {
+		if entry.Command.Name() == "schema" {
+			continue
+		}
+		if name := entry.Command.Name(); name != "status" && name != "table_list" {
+			t.Fatalf("h.Exec failed: %s is sent", name)
+		}
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	data := []struct {
+		s    string
+		sep  string
+		want int
+	}{
+		{`a,b,c`, ",", 3},
+		{`snippet_html(a, b),c`, ",", 2},
+		{`"x,y",z`, ",", 2},
+		{`title * 10 || f(a || b, c)`, "||", 2},
+	}
+	for _, d := range data {
+		if actual := len(splitList(d.s, d.sep)); actual != d.want {
+			t.Fatalf("splitList failed: s = %s, actual = %d, want = %d", d.s, actual, d.want)
+		}
+	}
+}